@@ -1,11 +1,14 @@
 package tsf
 
 import (
+	"time"
+
 	"github.com/tencentyun/tsf-go/naming/consul"
 	"github.com/tencentyun/tsf-go/pkg/sys/env"
 	"github.com/tencentyun/tsf-go/pkg/version"
 
 	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/registry"
 	"github.com/go-kratos/swagger-api/openapiv2"
 	"google.golang.org/grpc"
 )
@@ -33,6 +36,13 @@ func EnableReigstry(enable bool) Option {
 	}
 }
 
+// WithRegistrar sets the registrar of instance, default is consul.
+func WithRegistrar(r registry.Registrar) Option {
+	return func(a *appOptions) {
+		a.registrar = r
+	}
+}
+
 func Medata(metadata map[string]string) Option {
 	return func(a *appOptions) {
 		a.metadata = metadata
@@ -40,11 +50,14 @@ func Medata(metadata map[string]string) Option {
 }
 
 type appOptions struct {
-	protoService   string
-	srv            *grpc.Server
-	apiMeta        bool
-	enableReigstry bool
-	metadata       map[string]string
+	protoService    string
+	srv             *grpc.Server
+	apiMeta         bool
+	enableReigstry  bool
+	metadata        map[string]string
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+	registrar       registry.Registrar
 }
 
 func APIMeta(enable bool) Option {
@@ -100,12 +113,26 @@ func Metadata(optFuncs ...Option) (opt kratos.Option) {
 func ID(optFuncs ...Option) kratos.Option {
 	return kratos.ID(env.InstanceId())
 }
+
+// Registrar registers the instance to consul, the instance is deregistered before the servers stop.
 func Registrar(optFuncs ...Option) kratos.Option {
-	return kratos.Registrar(consul.DefaultConsul())
+	o := appOptions{shutdownDelay: defaultShutdownDelay, shutdownTimeout: defaultShutdownTimeout}
+	for _, opt := range optFuncs {
+		opt(&o)
+	}
+	var r registry.Registrar = consul.DefaultConsul()
+	if o.registrar != nil {
+		r = o.registrar
+	}
+	return kratos.Registrar(newShutdownRegistrar(r, o.shutdownDelay, o.shutdownTimeout))
 }
 
 func AppOptions(opts ...Option) []kratos.Option {
-	o := appOptions{enableReigstry: true}
+	o := appOptions{
+		enableReigstry:  true,
+		shutdownDelay:   defaultShutdownDelay,
+		shutdownTimeout: defaultShutdownTimeout,
+	}
 	for _, opt := range opts {
 		opt(&o)
 	}
@@ -113,8 +140,12 @@ func AppOptions(opts ...Option) []kratos.Option {
 	kopts := []kratos.Option{
 		ID(opts...), Metadata(opts...),
 	}
+	// 通过包装Registrar在应用退出时先注销实例，Run在server停止后执行剩余的优雅退出步骤
 	if o.enableReigstry {
 		kopts = append(kopts, Registrar(opts...))
+	} else {
+		kopts = append(kopts, kratos.Registrar(newShutdownRegistrar(nil, o.shutdownDelay, o.shutdownTimeout)))
 	}
+	kopts = append(kopts, kratos.RegistrarTimeout(o.shutdownTimeout))
 	return kopts
 }
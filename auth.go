@@ -24,6 +24,9 @@ func authMiddleware() middleware.Middleware {
 				serviceName := k.Name()
 				builder := &authenticator.Builder{}
 				authen = builder.Build(consul.DefaultConsul(), naming.NewService(env.NamespaceID(), serviceName))
+				if c, ok := authen.(interface{ Close() }); ok {
+					onShutdown(c.Close)
+				}
 			})
 			_, operation := ServerOperation(ctx)
 			// 鉴权
@@ -6,6 +6,13 @@ import (
 	"github.com/tencentyun/tsf-go/naming"
 )
 
+const (
+	// TrailerCPU is the trailer key of the cpu usage(permille) reported by server
+	TrailerCPU = "tsf-load-cpu"
+	// TrailerInflight is the trailer key of the inflight requests reported by server
+	TrailerInflight = "tsf-load-inflight"
+)
+
 // DoneInfo is callback when rpc done
 type DoneInfo struct {
 	Err     error
@@ -5,6 +5,7 @@ import (
 	"context"
 	"math"
 	"math/rand"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -27,6 +28,13 @@ const (
 
 	forceGap = int64(time.Second * 3)
 
+	// server load reported in trailer is ignored if not refreshed within loadExpire
+	loadExpire = int64(time.Second * 3)
+	// cost = lag * inflight * (cpu + cpuBase) / cpuBase
+	cpuBase = 200
+	// health is reduced when server cpu usage(permille) is higher than highCPU
+	highCPU = 800
+
 	Name = "p2c"
 )
 
@@ -54,7 +62,13 @@ type subConn struct {
 
 	predictTs int64
 	predict   int64
-	lk        sync.RWMutex
+
+	// server load reported in trailer
+	svrCPU      uint64
+	svrInflight int64
+	svrStamp    int64
+
+	lk sync.RWMutex
 	//2ms,5ms,20ms,50ms,100ms,200ms,400ms,800ms,1600ms,>1600ms
 	lags [10]metric.RollingCounter
 }
@@ -73,12 +87,40 @@ func newSubConn(node *naming.Instance) *subConn {
 	return s
 }
 
-func (sc *subConn) valid() bool {
-	return sc.health() >= 500
+func (sc *subConn) valid(now int64) bool {
+	return sc.health(now) >= 500
+}
+
+func (sc *subConn) health(now int64) uint64 {
+	success := atomic.LoadUint64(&sc.success)
+	if cpu, _, ok := sc.serverLoad(now); ok && cpu > highCPU {
+		// 服务端CPU过高时按比例降低健康度，CPU打满时健康度为0
+		if cpu >= 1000 {
+			return 0
+		}
+		success = success * (1000 - cpu) / (1000 - highCPU)
+	}
+	return success
+}
+
+// serverLoad returns the load reported by server, ok is false if expired.
+func (sc *subConn) serverLoad(now int64) (cpu uint64, inflight int64, ok bool) {
+	if now-atomic.LoadInt64(&sc.svrStamp) > loadExpire {
+		return
+	}
+	return atomic.LoadUint64(&sc.svrCPU), atomic.LoadInt64(&sc.svrInflight), true
 }
 
-func (sc *subConn) health() uint64 {
-	return atomic.LoadUint64(&sc.success)
+// updateLoad updates the server load reported in trailer.
+func (sc *subConn) updateLoad(now int64, trailer map[string]string) {
+	cpu, err := strconv.ParseUint(trailer[balancer.TrailerCPU], 10, 64)
+	if err != nil {
+		return
+	}
+	inflight, _ := strconv.ParseInt(trailer[balancer.TrailerInflight], 10, 64)
+	atomic.StoreUint64(&sc.svrCPU, cpu)
+	atomic.StoreInt64(&sc.svrInflight, inflight)
+	atomic.StoreInt64(&sc.svrStamp, now)
 }
 
 func (sc *subConn) load(now int64) uint64 {
@@ -117,10 +159,19 @@ func (sc *subConn) load(now int64) uint64 {
 	if predict > avgLag {
 		avgLag = predict
 	}
-	load := uint64(avgLag) * uint64(atomic.LoadInt64(&sc.inflight))
+	inflight := atomic.LoadInt64(&sc.inflight)
+	cpu, svrInflight, ok := sc.serverLoad(now)
+	if ok && svrInflight > inflight {
+		// 服务端上报的并发数包含了其他客户端的请求，更能反映实例的真实负载
+		inflight = svrInflight
+	}
+	load := uint64(avgLag) * uint64(inflight)
 	if load == 0 {
 		// penalty是初始化没有数据时的惩罚值，默认为1e9 * 20
-		load = penalty * uint64(atomic.LoadInt64(&sc.inflight))
+		load = penalty * uint64(inflight)
+	}
+	if ok {
+		load = load * (cpu + cpuBase) / cpuBase
 	}
 	return load
 }
@@ -135,6 +186,7 @@ type statistic struct {
 	inflight int64
 	reqs     int64
 	predict  time.Duration
+	svrCPU   uint64
 }
 
 // New p2c
@@ -178,7 +230,8 @@ func (p *P2cPicker) prePick(nodes []naming.Instance) (nodeA *subConn, nodeB *sub
 		}
 		p.lk.Unlock()
 
-		if nodeA.valid() || nodeB.valid() {
+		now := time.Now().UnixNano()
+		if nodeA.valid(now) || nodeB.valid(now) {
 			break
 		}
 	}
@@ -202,7 +255,7 @@ func (p *P2cPicker) Pick(ctx context.Context, nodes []naming.Instance) (*naming.
 	} else {
 		nodeA, nodeB := p.prePick(nodes)
 		// meta.Weight为服务发布者在disocvery中设置的权重
-		if nodeA.load(start)*nodeB.health() > nodeB.load(start)*nodeA.health() {
+		if nodeA.load(start)*nodeB.health(start) > nodeB.load(start)*nodeA.health(start) {
 			pc, upc = nodeB, nodeA
 		} else {
 			pc, upc = nodeA, nodeB
@@ -265,6 +318,7 @@ func (p *P2cPicker) Pick(ctx context.Context, nodes []naming.Instance) (*naming.
 		oldSuc := atomic.LoadUint64(&pc.success)
 		success = uint64(float64(oldSuc)*w + float64(success)*(1.0-w))
 		atomic.StoreUint64(&pc.success, success)
+		pc.updateLoad(now, di.Trailer)
 
 		logTs := atomic.LoadInt64(&p.logTs)
 		if now-logTs > int64(time.Second*3) {
@@ -292,6 +346,7 @@ func (p *P2cPicker) PrintStats() {
 		stat.reqs = atomic.SwapInt64(&conn.reqs, 0)
 		stat.load = conn.load(now)
 		stat.predict = time.Duration(atomic.LoadInt64(&conn.predict))
+		stat.svrCPU, _, _ = conn.serverLoad(now)
 		stats = append(stats, stat)
 		if serverName == "" {
 			serverName = conn.node.Service.Name
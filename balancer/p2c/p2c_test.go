@@ -0,0 +1,100 @@
+package p2c
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/balancer"
+	"github.com/tencentyun/tsf-go/naming"
+)
+
+var testNodes = []naming.Instance{
+	{Service: &naming.Service{Name: "provider"}, Host: "127.0.0.1", Port: 8080},
+	{Service: &naming.Service{Name: "provider"}, Host: "127.0.0.1", Port: 8081},
+}
+
+// newPicker returns a picker whose nodes have the same latency,
+// the server load in trailers is reported to the corresponding node.
+func newPicker(trailers ...map[string]string) *P2cPicker {
+	p := New(nil).(*P2cPicker)
+	now := time.Now().UnixNano()
+	for i := range testNodes {
+		sc := newSubConn(&testNodes[i])
+		// 测试中的请求耗时只有纳秒级的噪声，固定为相同的延迟
+		sc.lag, sc.stamp, sc.pick = int64(10*time.Millisecond), now, now
+		p.subConns[testNodes[i].Addr()] = sc
+	}
+	for i, trailer := range trailers {
+		p.subConns[testNodes[i].Addr()].updateLoad(now, trailer)
+	}
+	return p
+}
+
+func pickCount(p *P2cPicker, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		node, done := p.Pick(context.Background(), testNodes)
+		counts[node.Addr()]++
+		done(balancer.DoneInfo{})
+	}
+	return counts
+}
+
+func TestServerCPU(t *testing.T) {
+	p := newPicker(
+		map[string]string{balancer.TrailerCPU: "900", balancer.TrailerInflight: "1"},
+		map[string]string{balancer.TrailerCPU: "100", balancer.TrailerInflight: "1"},
+	)
+	counts := pickCount(p, 1000)
+	// cpu高的实例代价更高且健康度降低，很少被选中
+	assert.True(t, counts[testNodes[1].Addr()] > 900, "counts: %v", counts)
+}
+
+func TestServerInflight(t *testing.T) {
+	p := newPicker(
+		map[string]string{balancer.TrailerCPU: "0", balancer.TrailerInflight: "100"},
+		map[string]string{balancer.TrailerCPU: "0", balancer.TrailerInflight: "0"},
+	)
+	counts := pickCount(p, 1000)
+	assert.True(t, counts[testNodes[1].Addr()] > 900, "counts: %v", counts)
+}
+
+func TestServerLoadExpire(t *testing.T) {
+	sc := newSubConn(&testNodes[0])
+	base := time.Now().UnixNano()
+	idle := sc.load(base)
+	sc.updateLoad(base, map[string]string{balancer.TrailerCPU: "900", balancer.TrailerInflight: "100"})
+
+	// 3s内上报的负载有效
+	now := base + loadExpire
+	cpu, inflight, ok := sc.serverLoad(now)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(900), cpu)
+	assert.Equal(t, int64(100), inflight)
+	assert.True(t, sc.load(now) > idle)
+	assert.True(t, sc.health(now) < 1000)
+
+	// 超过3s没有刷新则忽略
+	now = base + loadExpire + 1
+	_, _, ok = sc.serverLoad(now)
+	assert.False(t, ok)
+	assert.Equal(t, idle, sc.load(now))
+	assert.Equal(t, uint64(1000), sc.health(now))
+
+	// 没有上报cpu的trailer不会覆盖负载
+	sc.updateLoad(now, map[string]string{})
+	_, _, ok = sc.serverLoad(now)
+	assert.False(t, ok)
+}
+
+func TestDoneTrailer(t *testing.T) {
+	p := newPicker()
+	node, done := p.Pick(context.Background(), testNodes)
+	done(balancer.DoneInfo{Trailer: map[string]string{balancer.TrailerCPU: "900", balancer.TrailerInflight: "100"}})
+	cpu, inflight, ok := p.subConns[node.Addr()].serverLoad(time.Now().UnixNano())
+	assert.True(t, ok)
+	assert.Equal(t, uint64(900), cpu)
+	assert.Equal(t, int64(100), inflight)
+}
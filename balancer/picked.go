@@ -0,0 +1,107 @@
+package balancer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tencentyun/tsf-go/naming"
+)
+
+type pickedKey struct{}
+
+// Picked records the instances picked during one logical call,
+// so that a retry can avoid the instance which has just failed.
+type Picked struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+// NewPickedContext returns a new Context that carries an empty Picked record.
+func NewPickedContext(ctx context.Context) (context.Context, *Picked) {
+	p := &Picked{}
+	return context.WithValue(ctx, pickedKey{}, p), p
+}
+
+// PickedFromContext returns the Picked record stored in ctx, if any.
+func PickedFromContext(ctx context.Context) (p *Picked, ok bool) {
+	p, ok = ctx.Value(pickedKey{}).(*Picked)
+	return
+}
+
+// Add records the address of a picked instance.
+func (p *Picked) Add(addr string) {
+	p.mu.Lock()
+	p.addrs = append(p.addrs, addr)
+	p.mu.Unlock()
+}
+
+// Contains reports whether the address has been picked before.
+func (p *Picked) Contains(addr string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, a := range p.addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Last returns the address of the latest picked instance.
+func (p *Picked) Last() (addr string) {
+	p.mu.Lock()
+	if len(p.addrs) > 0 {
+		addr = p.addrs[len(p.addrs)-1]
+	}
+	p.mu.Unlock()
+	return
+}
+
+// Exclude filters out the instances which have been picked in ctx,
+// the original nodes are returned if all of them have been picked.
+func Exclude(ctx context.Context, nodes []naming.Instance) []naming.Instance {
+	p, ok := PickedFromContext(ctx)
+	if !ok {
+		return nodes
+	}
+	var res []naming.Instance
+	for _, node := range nodes {
+		if !p.Contains(node.Addr()) {
+			res = append(res, node)
+		}
+	}
+	if len(res) == 0 {
+		return nodes
+	}
+	return res
+}
+
+type pickHookKey struct{}
+
+// WithPickHook returns a new Context that carries the hook, which is called by Record
+// with the address of the picked instance before the call is sent.
+// The hook already carried in ctx is still called.
+func WithPickHook(ctx context.Context, hook func(addr string)) context.Context {
+	if prev, ok := ctx.Value(pickHookKey{}).(func(string)); ok {
+		next := hook
+		hook = func(addr string) {
+			prev(addr)
+			next(addr)
+		}
+	}
+	return context.WithValue(ctx, pickHookKey{}, hook)
+}
+
+// Record records the picked instance into ctx if ctx carries a Picked record,
+// and calls the pick hook carried in ctx.
+func Record(ctx context.Context, node *naming.Instance) {
+	if node == nil {
+		return
+	}
+	if p, ok := PickedFromContext(ctx); ok {
+		p.Add(node.Addr())
+	}
+	if hook, ok := ctx.Value(pickHookKey{}).(func(string)); ok {
+		hook(node.Addr())
+	}
+}
@@ -2,15 +2,150 @@ package tsf
 
 import (
 	"context"
+	"sync"
+	"time"
 
+	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/errors"
 	"github.com/go-kratos/kratos/v2/middleware"
 	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/tencentyun/tsf-go/balancer"
 	"github.com/tencentyun/tsf-go/breaker"
+	"github.com/tencentyun/tsf-go/breaker/circuitbreaker"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/config/consul"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+	"github.com/tencentyun/tsf-go/tracing"
+	"github.com/tencentyun/tsf-go/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const fallbackSuffix = "#fallback"
+
+var (
+	cbMu            sync.Mutex
+	circuitBreakers = make(map[config.Source]*circuitbreaker.CircuitBreaker)
+)
+
+// WithBreakerRuleSource sets the config source of the circuit breaker rules published by tsf,
+// default is consul unless WithDiscovery sets another discovery or WithEnableDiscovery(false) is set.
+func WithBreakerRuleSource(source config.Source) ClientOption {
+	return func(o *clientOpionts) {
+		o.breakerRuleSource = source
+	}
+}
+
+// ruleSource returns nil if the circuit breaker rules should not be subscribed.
+func (o *clientOpionts) ruleSource() config.Source {
+	if o.breakerRuleSource != nil {
+		return o.breakerRuleSource
+	}
+	if !o.enableDiscovery || o.discovery != nil {
+		return nil
+	}
+	return consul.DefaultConsul()
+}
+
+// getCircuitBreaker subscribes the circuit breaker rules of local service from source,
+// and isolates the instances with open breaker from the default composite router.
+// The circuit breaker is shared by all middlewares with the same source.
+func getCircuitBreaker(ctx context.Context, source config.Source) *circuitbreaker.CircuitBreaker {
+	cbMu.Lock()
+	defer cbMu.Unlock()
+	if cb, ok := circuitBreakers[source]; ok {
+		return cb
+	}
+	serviceName := env.ServiceName()
+	if k, ok := kratos.FromContext(ctx); ok {
+		serviceName = k.Name()
+	}
+	builder := &circuitbreaker.Builder{}
+	cb := builder.Build(source, *naming.NewService(env.NamespaceID(), serviceName))
+	useDefaultComposite().AddFilter(cb)
+	onShutdown(cb.Close)
+	circuitBreakers[source] = cb
+	return cb
+}
+
+// BreakerFallback is called when the breaker rejects a call,
+// it can return cached or degraded responses.
+type BreakerFallback func(ctx context.Context, operation string, req interface{}) (reply interface{}, err error)
+
+// WithBreakerFallback sets the fallback for all operations rejected by breaker.
+func WithBreakerFallback(f BreakerFallback) ClientOption {
+	return func(o *clientOpionts) {
+		o.breakerFallback = f
+	}
+}
+
+// WithOperationFallback sets the fallback for the operation rejected by breaker,
+// it takes precedence over WithBreakerFallback.
+func WithOperationFallback(operation string, f BreakerFallback) ClientOption {
+	return func(o *clientOpionts) {
+		if o.operationFallbacks == nil {
+			o.operationFallbacks = make(map[string]BreakerFallback)
+		}
+		o.operationFallbacks[operation] = f
+	}
+}
+
+func (o *clientOpionts) fallback(operation string) BreakerFallback {
+	if f, ok := o.operationFallbacks[operation]; ok {
+		return f
+	}
+	return o.breakerFallback
+}
+
+type breakerRejectedKey struct{}
+
+// withBreakerRejected returns a ctx carrying a flag which is set when the call is rejected by breaker,
+// so that the call is still counted as rejected even if the fallback succeeded.
+func withBreakerRejected(ctx context.Context) (context.Context, *bool) {
+	var rejected bool
+	return context.WithValue(ctx, breakerRejectedKey{}, &rejected), &rejected
+}
+
+func markBreakerRejected(ctx context.Context) {
+	if rejected, ok := ctx.Value(breakerRejectedKey{}).(*bool); ok {
+		*rejected = true
+	}
+}
+
+// runFallback runs the fallback in a separate span and monitor stat,
+// the span is skipped if tracer is nil.
+func runFallback(ctx context.Context, tracer *tracing.Tracer, f BreakerFallback, req interface{}, cause error) (reply interface{}, err error) {
+	tr, _ := transport.FromClientContext(ctx)
+	method, operation := ClientOperation(ctx)
+	remoteService, _ := util.ParseTarget(tr.Endpoint())
+
+	var span trace.Span
+	if tracer != nil {
+		ctx, span = tracer.Start(ctx, "fallback", operation+fallbackSuffix, nil)
+		span.SetAttributes(attribute.String("local.service", LocalEndpoint(ctx).Service))
+		span.SetAttributes(attribute.String("peer.service", remoteService))
+		span.SetAttributes(attribute.String("fallback.cause", cause.Error()))
+	}
+	stat := getClientStat(ctx, remoteService, operation+fallbackSuffix, method)
+	defer func() {
+		var code = 200
+		if err != nil {
+			code = int(errors.FromError(err).GetCode())
+		}
+		stat.Record(code)
+		if span != nil {
+			tracer.End(ctx, span, err)
+		}
+	}()
+	return f(ctx, tr.Operation(), req)
+}
+
+// BreakerMiddleware isolates the failed services, apis and instances by the circuit breaker
+// rules published by tsf, the static breaker config keyed by operation is used if no rule found.
 func BreakerMiddleware(opts ...ClientOption) middleware.Middleware {
-	var o clientOpionts
+	o := clientOpionts{enableDiscovery: true}
 	for _, opt := range opts {
 		opt(&o)
 	}
@@ -21,32 +156,54 @@ func BreakerMiddleware(opts ...ClientOption) middleware.Middleware {
 			}
 		}
 	}
+	failed := func(ctx context.Context, operation string, err error) bool {
+		if err == nil {
+			return false
+		}
+		if o.breakerErrorHook != nil {
+			return !o.breakerErrorHook(ctx, operation, err)
+		}
+		return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.FromError(err).GetCode() >= 500
+	}
+	source := o.ruleSource()
 	group := breaker.NewGroup(o.breakerCfg)
+	tracer, e := tracing.NewTracer(trace.SpanKindInternal)
+	if e != nil {
+		log.DefaultLog.Errorw("msg", "create fallback tracer failed,fallback will not be traced!", "err", e)
+	}
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (reply interface{}, err error) {
-			if tr, ok := transport.FromClientContext(ctx); ok {
-				if tr.Operation() != "" {
-					breaker := group.Get(tr.Operation())
-					if err = breaker.Allow(); err != nil {
-						return
-					}
-					defer func() {
-						if err != nil {
-							if o.breakerErrorHook != nil {
-								if !o.breakerErrorHook(ctx, tr.Operation(), err) {
-									breaker.MarkFailed()
-									return
-								}
-							} else if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.FromError(err).GetCode() >= 500 {
-								breaker.MarkFailed()
-								return
-							}
-						}
-						breaker.MarkSuccess()
-					}()
+			tr, ok := transport.FromClientContext(ctx)
+			if !ok || tr.Operation() == "" {
+				return handler(ctx, req)
+			}
+			// 熔断拒绝时执行降级逻辑
+			reject := func(cause error) (interface{}, error) {
+				markBreakerRejected(ctx)
+				if f := o.fallback(tr.Operation()); f != nil {
+					return runFallback(ctx, tracer, f, req, cause)
+				}
+				return nil, cause
+			}
+			if source != nil {
+				call, err := getCircuitBreaker(ctx, source).Allow(ctx)
+				if err != nil {
+					return reject(err)
 				}
+				if call != nil {
+					// 负载均衡选中实例后、发送请求前执行实例级熔断的Allow
+					reply, err = handler(balancer.WithPickHook(ctx, call.Pick), req)
+					call.Done(!failed(ctx, tr.Operation(), err))
+					return reply, err
+				}
+			}
+			brk := group.Get(tr.Operation())
+			if err = brk.Allow(); err != nil {
+				return reject(err)
 			}
+			start := time.Now()
 			reply, err = handler(ctx, req)
+			breaker.Mark(brk, !failed(ctx, tr.Operation(), err), time.Since(start))
 			return
 		}
 	}
@@ -11,9 +11,30 @@ type Config struct {
 	// set true to close breaker
 	SwitchOff bool
 
+	// 熔断器实现: TypeSRE 或 TypeClassic
+	// 默认值 TypeSRE
+	Type string
+
 	// Google
 	K float64
 
+	// Classic
+	// 统计窗口内失败率达到该值时熔断
+	// 默认值 0.5
+	FailureRatio float64
+	// 耗时超过SlowCallDuration的请求为慢调用,慢调用率达到SlowRatio时熔断
+	// SlowCallDuration为0时不统计慢调用
+	SlowCallDuration time.Duration
+	// 默认值 1
+	SlowRatio float64
+	// 熔断打开后等待多久进入半开状态
+	// 默认值 5s
+	OpenDuration time.Duration
+	// 半开状态允许通过的探测请求数,全部成功后关闭熔断
+	// 探测请求在OpenDuration内没有全部返回结果时重新打开熔断
+	// 默认值 3
+	HalfOpenProbes int32
+
 	// 统计时间窗口
 	// 默认值 5s
 	Window time.Duration
@@ -37,8 +58,30 @@ func (conf *Config) fix() {
 	if conf.Window == 0 {
 		conf.Window = time.Duration(5 * time.Second)
 	}
+	if conf.Type == "" {
+		conf.Type = TypeSRE
+	}
+	if conf.FailureRatio == 0 {
+		conf.FailureRatio = 0.5
+	}
+	if conf.SlowRatio == 0 {
+		conf.SlowRatio = 1
+	}
+	if conf.OpenDuration == 0 {
+		conf.OpenDuration = 5 * time.Second
+	}
+	if conf.HalfOpenProbes == 0 {
+		conf.HalfOpenProbes = 3
+	}
 }
 
+const (
+	// TypeSRE google sre adaptive breaker
+	TypeSRE = "sre"
+	// TypeClassic three-state breaker trips on failure ratio or slow call ratio
+	TypeClassic = "classic"
+)
+
 // Breaker is a CircuitBreaker pattern.
 // FIXME on int32 atomic.LoadInt32(&b.on) == _switchOn
 type Breaker interface {
@@ -47,12 +90,50 @@ type Breaker interface {
 	MarkFailed()
 }
 
+// LatencyBreaker is a Breaker which also takes the latency of calls into account,
+// e.g. classic breaker trips on slow calls.
+type LatencyBreaker interface {
+	Breaker
+	Mark(success bool, latency time.Duration)
+}
+
+// StateBreaker is a Breaker which reports its state without changing it,
+// e.g. to filter instances without taking the probe quota of half open breakers.
+type StateBreaker interface {
+	Breaker
+	State() int32
+}
+
+// State returns the state of the breaker without changing it,
+// StateClosed is returned if the breaker does not report its state.
+func State(b Breaker) int32 {
+	if sb, ok := b.(StateBreaker); ok {
+		return sb.State()
+	}
+	return StateClosed
+}
+
+// Mark marks the result of a call to the breaker,
+// the latency is recorded if the breaker supports it.
+func Mark(b Breaker, success bool, latency time.Duration) {
+	if lb, ok := b.(LatencyBreaker); ok {
+		lb.Mark(success, latency)
+		return
+	}
+	if success {
+		b.MarkSuccess()
+	} else {
+		b.MarkFailed()
+	}
+}
+
 // Group represents a class of CircuitBreaker and forms a namespace in which
 // units of CircuitBreaker.
 type Group struct {
-	mu   sync.RWMutex
-	brks map[string]Breaker
-	conf *Config
+	mu        sync.RWMutex
+	brks      map[string]Breaker
+	conf      *Config
+	listeners []Listener
 }
 
 const (
@@ -109,6 +190,9 @@ func Go(name string, run, fallback func() error) error {
 // newBreaker new a breaker.
 func newBreaker(c *Config) (b Breaker) {
 	// factory
+	if c.Type == TypeClassic {
+		return newClassic(c)
+	}
 	return newSRE(c)
 }
 
@@ -138,8 +222,15 @@ func (g *Group) Get(key string) Breaker {
 	}
 	// NOTE here may new multi breaker for rarely case, let gc drop it.
 	brk = newBreaker(conf)
+	if o, ok := brk.(observable); ok {
+		o.onStateChange(func(from, to int32) {
+			g.emit(key, from, to)
+		})
+	}
 	g.mu.Lock()
-	if _, ok = g.brks[key]; !ok {
+	if exist, ok := g.brks[key]; ok {
+		brk = exist
+	} else {
 		g.brks[key] = brk
 	}
 	g.mu.Unlock()
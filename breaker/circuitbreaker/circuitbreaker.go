@@ -0,0 +1,294 @@
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/tencentyun/tsf-go/breaker"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	"github.com/tencentyun/tsf-go/route"
+)
+
+var _ route.Router = &CircuitBreaker{}
+
+// 实例级熔断默认最多隔离的实例百分比
+const defaultMaxEjectionPercent = 50
+
+type Builder struct {
+}
+
+// Build subscribes the circuit breaker rules of the local service svc.
+func (b *Builder) Build(cfg config.Source, svc naming.Service) *CircuitBreaker {
+	watcher := cfg.Subscribe(breakerKey(svc))
+	c := &CircuitBreaker{
+		watcher:    watcher,
+		svc:        svc,
+		targets:    map[naming.Service][]*strategy{},
+		strategies: map[string]*strategy{},
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	go c.refreshRule()
+	return c
+}
+
+type strategy struct {
+	Strategy
+	level string
+	conf  breaker.Config
+	group *breaker.Group
+}
+
+// CircuitBreaker isolates the target services, apis or instances
+// by the circuit breaker rules published by tsf.
+type CircuitBreaker struct {
+	watcher config.Watcher
+	svc     naming.Service
+
+	mu         sync.RWMutex
+	targets    map[naming.Service][]*strategy
+	strategies map[string]*strategy
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Call holds the breakers admitted for one call.
+type Call struct {
+	instances []*strategy
+
+	mu   sync.Mutex
+	brks []breaker.Breaker
+}
+
+// Pick admits the picked instance by the instance level breakers,
+// it must be called once with the address of the picked instance before the call is sent.
+func (c *Call) Pick(addr string) {
+	for _, s := range c.instances {
+		// 实例最多被隔离maxEjectionPercent，被拒绝的实例仍然会被调用，但结果不计入熔断器
+		brk := s.group.Get(addr)
+		if brk.Allow() != nil {
+			continue
+		}
+		c.mu.Lock()
+		c.brks = append(c.brks, brk)
+		c.mu.Unlock()
+	}
+}
+
+// Done records the result of the call into all admitted breakers.
+func (c *Call) Done(success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, brk := range c.brks {
+		if success {
+			brk.MarkSuccess()
+		} else {
+			brk.MarkFailed()
+		}
+	}
+}
+
+// Allow checks the service and api level breakers of the call in ctx.
+// call is nil if no rule found for the target service, otherwise call.Pick must be
+// called with the picked instance and call.Done with the result of the call.
+func (c *CircuitBreaker) Allow(ctx context.Context) (call *Call, err error) {
+	svc, ok := target(ctx)
+	if !ok {
+		return
+	}
+	strategies := c.lookup(svc)
+	if len(strategies) == 0 {
+		return
+	}
+	method, path := api(ctx)
+	call = &Call{}
+	for _, s := range strategies {
+		if !s.match(method, path) {
+			continue
+		}
+		switch s.level {
+		case LevelInstance:
+			// 实例级熔断在负载均衡选中实例时生效
+			call.instances = append(call.instances, s)
+		case LevelAPI:
+			brk := s.group.Get(path)
+			if err = brk.Allow(); err != nil {
+				log.DefaultLog.WithContext(ctx).Debugw("msg", "api circuit breaker open,reject request!", "svc", svc, "api", path)
+				return nil, err
+			}
+			call.brks = append(call.brks, brk)
+		default:
+			brk := s.group.Get("")
+			if err = brk.Allow(); err != nil {
+				log.DefaultLog.WithContext(ctx).Debugw("msg", "service circuit breaker open,reject request!", "svc", svc)
+				return nil, err
+			}
+			call.brks = append(call.brks, brk)
+		}
+	}
+	return
+}
+
+// Select filters out the instances whose instance level breaker is open,
+// at most maxEjectionPercent of the instances are isolated.
+// It only reads the state of the breakers, Allow is called by Call.Pick for the picked instance.
+func (c *CircuitBreaker) Select(ctx context.Context, svc naming.Service, nodes []naming.Instance) []naming.Instance {
+	if len(nodes) == 0 {
+		return nodes
+	}
+	strategies := c.lookup(*naming.NewService(svc.Namespace, svc.Name))
+	if len(strategies) == 0 {
+		return nodes
+	}
+	method, path := api(ctx)
+	selects := nodes
+	for _, s := range strategies {
+		if s.level != LevelInstance || !s.match(method, path) {
+			continue
+		}
+		percent := s.MaxEjectionPercent
+		if percent <= 0 || percent > 100 {
+			percent = defaultMaxEjectionPercent
+		}
+		max := len(nodes) * int(percent) / 100
+		var ejected int
+		var res []naming.Instance
+		for _, node := range selects {
+			if ejected < max && breaker.State(s.group.Get(node.Addr())) == breaker.StateOpen {
+				ejected++
+				continue
+			}
+			res = append(res, node)
+		}
+		if ejected > 0 {
+			log.DefaultLog.WithContext(ctx).Debugw("msg", "instance circuit breaker open,isolate instances!", "svc", svc, "ejected", ejected)
+		}
+		selects = res
+	}
+	if len(selects) == 0 {
+		return nodes
+	}
+	return selects
+}
+
+// Close stops watching the circuit breaker rules.
+func (c *CircuitBreaker) Close() {
+	c.cancel()
+	c.watcher.Close()
+}
+
+func (c *CircuitBreaker) lookup(svc naming.Service) []*strategy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.targets[svc]
+}
+
+func (c *CircuitBreaker) refreshRule() {
+	key := breakerKey(c.svc)
+	for {
+		specs, err := c.watcher.Watch(c.ctx)
+		if err != nil {
+			if errors.IsGatewayTimeout(err) || errors.IsClientClosed(err) {
+				log.DefaultLog.Errorw("msg", "watch circuitbreaker config deadline or clsoe!exit now!", "err", err)
+				return
+			}
+			log.DefaultLog.Errorw("msg", "watch circuitbreaker config failed!", "err", err)
+			continue
+		}
+		var rules []Rule
+		for _, spec := range specs {
+			if spec.Key != key {
+				err = fmt.Errorf("found invalid circuitbreaker config key!")
+				log.DefaultLog.Errorw("msg", "found invalid circuitbreaker config key!", "key", spec.Key, "expect", key)
+				continue
+			}
+			err = spec.Data.Unmarshal(&rules)
+			if err != nil {
+				log.DefaultLog.Errorw("msg", "unmarshal circuitbreaker config failed!", "err", err, "raw", string(spec.Data.Raw()))
+				continue
+			}
+		}
+		if len(rules) == 0 && err != nil {
+			log.DefaultLog.Error("get circuitbreaker config failed,not override old data!")
+			continue
+		}
+		var valids []Rule
+		for _, rule := range rules {
+			if !rule.valid() {
+				log.DefaultLog.Errorw("msg", "found invalid circuitbreaker rule,ignore it!", "target", rule.TargetServiceName, "level", rule.IsolationLevel)
+				continue
+			}
+			valids = append(valids, rule)
+		}
+		log.DefaultLog.Infof("[circuitbreaker] found new circuitbreaker rules,replace now!rules: %v", valids)
+		c.update(valids)
+	}
+}
+
+// update replaces the rules, the breaker group of an unchanged strategy is
+// reused and reloaded if its config changed.
+func (c *CircuitBreaker) update(rules []Rule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	targets := make(map[naming.Service][]*strategy, len(rules))
+	strategies := make(map[string]*strategy)
+	for i := range rules {
+		rule := &rules[i]
+		for _, st := range rule.Strategies {
+			key := st.key(rule)
+			conf := st.config()
+			// breaker fixes the config in place, keep the origin one for comparing
+			origin := *conf
+			s, ok := c.strategies[key]
+			if !ok {
+				s = &strategy{conf: origin, group: breaker.NewGroup(conf)}
+			} else if s.conf != origin {
+				s.conf = origin
+				s.group.Reload(conf)
+			}
+			s.Strategy = st
+			s.level = rule.IsolationLevel
+			strategies[key] = s
+			svc := rule.service()
+			targets[svc] = append(targets[svc], s)
+		}
+	}
+	c.targets = targets
+	c.strategies = strategies
+}
+
+// target returns the target service of the client call in ctx.
+func target(ctx context.Context) (svc naming.Service, ok bool) {
+	name, _ := meta.Sys(ctx, meta.DestKey(meta.ServiceName)).(string)
+	if name == "" {
+		return
+	}
+	namespace, _ := meta.Sys(ctx, meta.DestKey(meta.ServiceNamespace)).(string)
+	return *naming.NewService(namespace, name), true
+}
+
+// api returns the http method and path of the client call in ctx,
+// the operation is used as path for grpc call.
+func api(ctx context.Context) (method string, path string) {
+	tr, ok := transport.FromClientContext(ctx)
+	if !ok {
+		path, _ = meta.Sys(ctx, meta.DestKey(meta.Interface)).(string)
+		return
+	}
+	if ht, ok := tr.(*khttp.Transport); ok && ht.Request() != nil {
+		return ht.Request().Method, ht.Request().URL.Path
+	}
+	return http.MethodPost, tr.Operation()
+}
+
+func breakerKey(svc naming.Service) string {
+	return fmt.Sprintf("circuitbreaker/%s/%s/data", svc.Namespace, svc.Name)
+}
@@ -0,0 +1,173 @@
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/breaker"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+)
+
+func newCircuitBreaker(rules ...Rule) *CircuitBreaker {
+	c := &CircuitBreaker{
+		targets:    map[naming.Service][]*strategy{},
+		strategies: map[string]*strategy{},
+	}
+	c.update(rules)
+	return c
+}
+
+func callContext(service string) context.Context {
+	return meta.WithSys(context.Background(),
+		meta.SysPair{Key: meta.DestKey(meta.ServiceName), Value: service},
+		meta.SysPair{Key: meta.DestKey(meta.Interface), Value: "/hello"},
+	)
+}
+
+func newRule(level string) Rule {
+	return Rule{
+		TargetServiceName: "provider",
+		IsolationLevel:    level,
+		Strategies: []Strategy{{
+			SlidingWindowSize:    10,
+			MinimumNumberOfCalls: 10,
+			FailureRateThreshold: 50,
+			MaxEjectionPercent:   50,
+		}},
+	}
+}
+
+func TestStrategyConfig(t *testing.T) {
+	s := Strategy{FailureRateThreshold: 75, MinimumNumberOfCalls: 20, SlidingWindowSize: 5, WaitDurationInOpenState: 3}
+	conf := s.config()
+	assert.Equal(t, breaker.TypeClassic, conf.Type)
+	assert.Equal(t, 0.75, conf.FailureRatio)
+	assert.Equal(t, int64(20), conf.Request)
+	assert.Equal(t, 5*time.Second, conf.Window)
+	assert.Equal(t, 3*time.Second, conf.OpenDuration)
+
+	s.APIs = []API{{Method: "GET", Path: "/hello"}}
+	assert.True(t, s.match("get", "/hello"))
+	assert.False(t, s.match("POST", "/hello"))
+	assert.False(t, s.match("GET", "/world"))
+}
+
+func TestWaitDurationInOpenState(t *testing.T) {
+	rule := newRule(LevelService)
+	rule.Strategies[0].WaitDurationInOpenState = 1
+	c := newCircuitBreaker(rule)
+	ctx := callContext("provider")
+	for i := 0; i < 10; i++ {
+		call, err := c.Allow(ctx)
+		assert.Nil(t, err)
+		call.Done(false)
+	}
+	brk := c.lookup(*naming.NewService("", "provider"))[0].group.Get("")
+	assert.Equal(t, breaker.StateOpen, breaker.State(brk))
+	_, err := c.Allow(ctx)
+	assert.NotNil(t, err)
+
+	// 等待waitDurationInOpenState之后进入半开状态，放行探测请求
+	time.Sleep(time.Second + 100*time.Millisecond)
+	assert.Equal(t, breaker.StateHalfopen, breaker.State(brk))
+	_, err = c.Allow(ctx)
+	assert.Nil(t, err)
+}
+
+func TestServiceLevel(t *testing.T) {
+	c := newCircuitBreaker(newRule(LevelService))
+
+	call, err := c.Allow(callContext("other"))
+	assert.Nil(t, err)
+	assert.Nil(t, call)
+
+	ctx := callContext("provider")
+	for i := 0; i < 100; i++ {
+		call, err = c.Allow(ctx)
+		if err != nil {
+			break
+		}
+		call.Done(false)
+	}
+	assert.NotNil(t, err)
+	brk := c.lookup(*naming.NewService("", "provider"))[0].group.Get("")
+	for i := 0; i < 1000; i++ {
+		brk.MarkFailed()
+	}
+
+	// reload the same rule keeps the breaker state
+	c.update([]Rule{newRule(LevelService)})
+	_, err = c.Allow(ctx)
+	assert.NotNil(t, err)
+
+	// config changed, breaker group is reloaded
+	rule := newRule(LevelService)
+	rule.Strategies[0].FailureRateThreshold = 60
+	c.update([]Rule{rule})
+	_, err = c.Allow(ctx)
+	assert.Nil(t, err)
+}
+
+func TestInstanceLevel(t *testing.T) {
+	c := newCircuitBreaker(newRule(LevelInstance))
+	ctx := callContext("provider")
+	svc := *naming.NewService("", "provider")
+	nodes := []naming.Instance{
+		{Host: "127.0.0.1", Port: 8080},
+		{Host: "127.0.0.1", Port: 8081},
+		{Host: "127.0.0.1", Port: 8082},
+	}
+	for i := 0; i < 1000; i++ {
+		for _, node := range nodes[:2] {
+			call, err := c.Allow(ctx)
+			assert.Nil(t, err)
+			call.Pick(node.Addr())
+			call.Done(false)
+		}
+	}
+	// Select只读取熔断状态，结果是确定的
+	for i := 0; i < 100; i++ {
+		res := c.Select(ctx, svc, nodes)
+		// at most 50% of the instances are isolated
+		assert.Equal(t, 2, len(res))
+		assert.NotEqual(t, nodes[0].Addr(), res[0].Addr())
+	}
+}
+
+func TestInstanceAdmission(t *testing.T) {
+	rule := newRule(LevelInstance)
+	rule.Strategies[0].WaitDurationInOpenState = 1
+	c := newCircuitBreaker(rule)
+	ctx := callContext("provider")
+	addr := "127.0.0.1:8080"
+	for i := 0; i < 10; i++ {
+		call, err := c.Allow(ctx)
+		assert.Nil(t, err)
+		call.Pick(addr)
+		call.Done(false)
+	}
+	brk := c.lookup(*naming.NewService("", "provider"))[0].group.Get(addr)
+	assert.Equal(t, breaker.StateOpen, breaker.State(brk))
+
+	time.Sleep(time.Second + 100*time.Millisecond)
+	// 半开状态下只放行HalfOpenProbes个探测请求，每个请求只在Pick时Allow一次
+	var probes []*Call
+	for i := 0; i < 3; i++ {
+		call, _ := c.Allow(ctx)
+		call.Pick(addr)
+		probes = append(probes, call)
+	}
+	// 超出探测名额的请求不计入熔断器
+	call, _ := c.Allow(ctx)
+	call.Pick(addr)
+	call.Done(false)
+	assert.Equal(t, breaker.StateHalfopen, breaker.State(brk))
+
+	for _, call := range probes {
+		call.Done(true)
+	}
+	assert.Equal(t, breaker.StateClosed, breaker.State(brk))
+}
@@ -0,0 +1,103 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tencentyun/tsf-go/breaker"
+	"github.com/tencentyun/tsf-go/naming"
+)
+
+// 熔断隔离级别
+const (
+	LevelService  = "SERVICE"
+	LevelAPI      = "API"
+	LevelInstance = "INSTANCE"
+)
+
+// Rule is the circuit breaker rule of a target service published by tsf.
+type Rule struct {
+	TargetServiceName string     `yaml:"targetServiceName"`
+	TargetNamespaceID string     `yaml:"targetNamespaceId"`
+	IsolationLevel    string     `yaml:"isolationLevel"`
+	Strategies        []Strategy `yaml:"strategyList"`
+}
+
+type Strategy struct {
+	// 统计窗口，单位秒
+	SlidingWindowSize int64 `yaml:"slidingWindowSize"`
+	// 统计窗口内请求量低于该值则不触发熔断
+	MinimumNumberOfCalls int64 `yaml:"minimumNumberOfCalls"`
+	// 失败率阈值，百分比
+	FailureRateThreshold int64 `yaml:"failureRateThreshold"`
+	// 熔断开启后的等待时间，单位秒
+	WaitDurationInOpenState int64 `yaml:"waitDurationInOpenState"`
+	// 实例级熔断时最多可隔离的实例百分比
+	MaxEjectionPercent int64 `yaml:"maxEjectionPercent"`
+	APIs               []API `yaml:"apiList"`
+}
+
+type API struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+}
+
+func (rule *Rule) service() naming.Service {
+	return *naming.NewService(rule.TargetNamespaceID, rule.TargetServiceName)
+}
+
+// valid 隔离级别或目标服务非法的规则直接忽略
+func (rule *Rule) valid() bool {
+	if rule.TargetServiceName == "" || len(rule.Strategies) == 0 {
+		return false
+	}
+	switch rule.IsolationLevel {
+	case LevelService, LevelAPI, LevelInstance:
+		return true
+	}
+	return false
+}
+
+// config converts the tsf strategy into classic breaker config,
+// the breaker opens when the failure rate reaches the threshold and turns to half open after the wait duration.
+func (s *Strategy) config() *breaker.Config {
+	rate := s.FailureRateThreshold
+	if rate <= 0 || rate > 100 {
+		rate = 50
+	}
+	conf := &breaker.Config{
+		Type:         breaker.TypeClassic,
+		FailureRatio: float64(rate) / 100,
+		Request:      s.MinimumNumberOfCalls,
+		Window:       time.Duration(s.SlidingWindowSize) * time.Second,
+		OpenDuration: time.Duration(s.WaitDurationInOpenState) * time.Second,
+	}
+	return conf
+}
+
+// key identifies a strategy of a rule, the breaker group of the strategy is
+// reused by reloading config if the key is unchanged after rules refreshed.
+func (s *Strategy) key(rule *Rule) string {
+	var apis []string
+	for _, api := range s.APIs {
+		apis = append(apis, api.Method+" "+api.Path)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", rule.TargetNamespaceID, rule.TargetServiceName, rule.IsolationLevel, strings.Join(apis, ","))
+}
+
+// match reports whether the call hits the api list, empty api list matches all.
+func (s *Strategy) match(method string, path string) bool {
+	if len(s.APIs) == 0 {
+		return true
+	}
+	for _, api := range s.APIs {
+		if api.Path != path {
+			continue
+		}
+		if api.Method == "" || method == "" || strings.EqualFold(api.Method, method) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/tencentyun/tsf-go/pkg/metric"
+)
+
+// classicBreaker is a three-state CircuitBreaker pattern,
+// it trips on failure ratio or slow call ratio within the window.
+type classicBreaker struct {
+	mu     sync.Mutex
+	opts   metric.RollingCounterOpts
+	total  metric.RollingCounter
+	failed metric.RollingCounter
+	slow   metric.RollingCounter
+
+	request      int64
+	failureRatio float64
+	slowDuration time.Duration
+	slowRatio    float64
+	openDuration time.Duration
+	probes       int32
+
+	state    int32
+	openedAt time.Time
+	// 进入半开状态的时间
+	halfOpenedAt time.Time
+	// 半开状态下已放行和已成功的探测请求数
+	probing   int32
+	succeeded int32
+
+	notify func(from, to int32)
+}
+
+// transition is a state change happened in a locked section,
+// listeners are notified after unlock.
+type transition struct {
+	from, to int32
+	changed  bool
+}
+
+func newClassic(c *Config) Breaker {
+	b := &classicBreaker{
+		opts: metric.RollingCounterOpts{
+			Size:           c.Bucket,
+			BucketDuration: time.Duration(int64(c.Window) / int64(c.Bucket)),
+		},
+		request:      c.Request,
+		failureRatio: c.FailureRatio,
+		slowDuration: c.SlowCallDuration,
+		slowRatio:    c.SlowRatio,
+		openDuration: c.OpenDuration,
+		probes:       c.HalfOpenProbes,
+		state:        StateClosed,
+	}
+	b.reset()
+	return b
+}
+
+// reset clears the statistics of the window.
+func (b *classicBreaker) reset() {
+	b.total = metric.NewRollingCounter(b.opts)
+	b.failed = metric.NewRollingCounter(b.opts)
+	b.slow = metric.NewRollingCounter(b.opts)
+}
+
+func (b *classicBreaker) Allow() error {
+	var t transition
+	defer b.emit(&t)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return errors.ServiceUnavailable(ReasonOpen, "circuit breaker is open")
+		}
+		b.setState(&t, StateHalfopen)
+		b.halfOpenedAt = time.Now()
+		b.probing, b.succeeded = 0, 0
+		fallthrough
+	case StateHalfopen:
+		if b.probing >= b.probes {
+			// 探测请求在OpenDuration内没有全部返回结果(比如调用方没有Mark)，
+			// 重新打开熔断，避免探测名额被永久占用
+			if time.Since(b.halfOpenedAt) >= b.openDuration {
+				b.open(&t)
+			}
+			return errors.ServiceUnavailable(ReasonOpen, "circuit breaker is half open")
+		}
+		b.probing++
+	}
+	return nil
+}
+
+// State returns StateHalfopen if the open duration has elapsed,
+// the breaker turns to half open on the next Allow.
+func (b *classicBreaker) State() int32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.openDuration {
+		return StateHalfopen
+	}
+	return b.state
+}
+
+func (b *classicBreaker) MarkSuccess() {
+	b.Mark(true, 0)
+}
+
+func (b *classicBreaker) MarkFailed() {
+	b.Mark(false, 0)
+}
+
+func (b *classicBreaker) Mark(success bool, latency time.Duration) {
+	slow := b.slowDuration > 0 && latency >= b.slowDuration
+	var t transition
+	defer b.emit(&t)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateHalfopen:
+		// 忽略上一轮探测请求迟到的结果
+		if b.succeeded >= b.probing {
+			return
+		}
+		// 任一探测请求失败则重新打开熔断
+		if !success || slow {
+			b.open(&t)
+			return
+		}
+		b.succeeded++
+		if b.succeeded >= b.probes {
+			b.setState(&t, StateClosed)
+			b.reset()
+		}
+	case StateClosed:
+		b.total.Add(1)
+		if !success {
+			b.failed.Add(1)
+		}
+		if slow {
+			b.slow.Add(1)
+		}
+		total := b.total.Value()
+		if total < b.request {
+			return
+		}
+		if float64(b.failed.Value()) >= float64(total)*b.failureRatio ||
+			(b.slowDuration > 0 && float64(b.slow.Value()) >= float64(total)*b.slowRatio) {
+			b.open(&t)
+		}
+	}
+}
+
+func (b *classicBreaker) open(t *transition) {
+	b.setState(t, StateOpen)
+	b.openedAt = time.Now()
+}
+
+func (b *classicBreaker) setState(t *transition, state int32) {
+	t.from, t.to, t.changed = b.state, state, true
+	b.state = state
+}
+
+func (b *classicBreaker) emit(t *transition) {
+	if t.changed && b.notify != nil {
+		b.notify(t.from, t.to)
+	}
+}
+
+func (b *classicBreaker) statistics() (state int32, requests int64, failureRatio float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	requests = b.total.Value()
+	if requests > 0 {
+		failureRatio = float64(b.failed.Value()) / float64(requests)
+	}
+	return b.state, requests, failureRatio
+}
+
+func (b *classicBreaker) onStateChange(f func(from, to int32)) {
+	b.notify = f
+}
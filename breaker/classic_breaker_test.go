@@ -0,0 +1,126 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getClassic() *classicBreaker {
+	conf := &Config{
+		Type:           TypeClassic,
+		Window:         time.Second,
+		Bucket:         10,
+		Request:        10,
+		FailureRatio:   0.5,
+		OpenDuration:   100 * time.Millisecond,
+		HalfOpenProbes: 2,
+	}
+	return NewGroup(conf).Get("").(*classicBreaker)
+}
+
+func TestClassicFailureRatio(t *testing.T) {
+	b := getClassic()
+	markSuccess(b, 5)
+	markFailed(b, 4)
+	assert.Nil(t, b.Allow())
+	markFailed(b, 1)
+	assert.NotNil(t, b.Allow())
+	assert.Equal(t, StateOpen, b.state)
+}
+
+func TestClassicHalfOpen(t *testing.T) {
+	b := getClassic()
+	markFailed(b, 10)
+	assert.NotNil(t, b.Allow())
+
+	time.Sleep(100 * time.Millisecond)
+	// only HalfOpenProbes requests are allowed in half open
+	assert.Nil(t, b.Allow())
+	assert.Nil(t, b.Allow())
+	assert.NotNil(t, b.Allow())
+	assert.Equal(t, StateHalfopen, b.state)
+	// any failed probe opens the breaker again
+	b.MarkSuccess()
+	b.MarkFailed()
+	assert.Equal(t, StateOpen, b.state)
+	assert.NotNil(t, b.Allow())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, b.Allow())
+	assert.Nil(t, b.Allow())
+	markSuccess(b, 2)
+	assert.Equal(t, StateClosed, b.state)
+	// statistics are reset after closed
+	markFailed(b, 9)
+	assert.Nil(t, b.Allow())
+}
+
+func TestClassicStaleProbe(t *testing.T) {
+	b := getClassic()
+	markFailed(b, 10)
+	time.Sleep(100 * time.Millisecond)
+	// probes are allowed but never marked
+	assert.Nil(t, b.Allow())
+	assert.Nil(t, b.Allow())
+	assert.NotNil(t, b.Allow())
+	assert.Equal(t, StateHalfopen, b.state)
+
+	// the probe window elapsed, the breaker opens again instead of rejecting forever
+	time.Sleep(100 * time.Millisecond)
+	assert.NotNil(t, b.Allow())
+	assert.Equal(t, StateOpen, b.state)
+	// late result of the stale probes is ignored in the next probe window
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, b.Allow())
+	markSuccess(b, 2)
+	assert.Equal(t, StateHalfopen, b.state)
+	assert.Nil(t, b.Allow())
+	markSuccess(b, 1)
+	assert.Equal(t, StateClosed, b.state)
+}
+
+func TestClassicState(t *testing.T) {
+	b := getClassic()
+	assert.Equal(t, StateClosed, State(b))
+	markFailed(b, 10)
+	assert.Equal(t, StateOpen, State(b))
+
+	time.Sleep(100 * time.Millisecond)
+	// State does not change the breaker or take the probe quota
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, StateHalfopen, State(b))
+	}
+	assert.Equal(t, StateOpen, b.state)
+	assert.Nil(t, b.Allow())
+	assert.Nil(t, b.Allow())
+	assert.NotNil(t, b.Allow())
+	assert.Equal(t, StateHalfopen, State(b))
+}
+
+func TestClassicSlowCall(t *testing.T) {
+	conf := &Config{
+		Type:             TypeClassic,
+		Request:          10,
+		SlowCallDuration: 100 * time.Millisecond,
+		SlowRatio:        0.5,
+	}
+	b := NewGroup(conf).Get("")
+	for i := 0; i < 5; i++ {
+		Mark(b, true, 10*time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		Mark(b, true, 200*time.Millisecond)
+	}
+	assert.Nil(t, b.Allow())
+	Mark(b, true, 200*time.Millisecond)
+	assert.NotNil(t, b.Allow())
+}
+
+func TestGroupType(t *testing.T) {
+	_, ok := NewGroup(&Config{}).Get("").(*sreBreaker)
+	assert.True(t, ok)
+	_, ok = NewGroup(&Config{Type: TypeClassic}).Get("").(*classicBreaker)
+	assert.True(t, ok)
+}
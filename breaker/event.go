@@ -0,0 +1,88 @@
+package breaker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tencentyun/tsf-go/log"
+)
+
+// ReasonOpen is the error reason returned when a call is rejected by breaker.
+const ReasonOpen = "circuit_breaker_open"
+
+// Event is a state transition of a breaker in group.
+type Event struct {
+	Key  string
+	From int32
+	To   int32
+	Time time.Time
+}
+
+// Listener is notified when the state of a breaker in group changed.
+type Listener func(Event)
+
+// Stat is the snapshot of a breaker in group.
+type Stat struct {
+	Key          string  `json:"key"`
+	State        string  `json:"state"`
+	Requests     int64   `json:"requests"`
+	FailureRatio float64 `json:"failure_ratio"`
+}
+
+// observable is implemented by the breakers which can be observed by group.
+type observable interface {
+	// stat returns the state, request count and failure ratio in the window.
+	statistics() (state int32, requests int64, failureRatio float64)
+	onStateChange(func(from, to int32))
+}
+
+// StateName returns the readable name of the breaker state.
+func StateName(state int32) string {
+	switch state {
+	case StateOpen:
+		return "open"
+	case StateClosed:
+		return "closed"
+	case StateHalfopen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// AddListener registers a listener for the state transitions of the breakers in group.
+func (g *Group) AddListener(l Listener) {
+	g.mu.Lock()
+	g.listeners = append(g.listeners, l)
+	g.mu.Unlock()
+}
+
+func (g *Group) emit(key string, from, to int32) {
+	log.DefaultLog.Infow("msg", "breaker state changed!", "key", key, "from", StateName(from), "to", StateName(to))
+	g.mu.RLock()
+	listeners := g.listeners
+	g.mu.RUnlock()
+	e := Event{Key: key, From: from, To: to, Time: time.Now()}
+	for _, l := range listeners {
+		l(e)
+	}
+}
+
+// Snapshot returns the state of each breaker in group, sorted by key.
+func (g *Group) Snapshot() []Stat {
+	g.mu.RLock()
+	stats := make([]Stat, 0, len(g.brks))
+	for key, brk := range g.brks {
+		s := Stat{Key: key, State: "unknown"}
+		if o, ok := brk.(observable); ok {
+			var state int32
+			state, s.Requests, s.FailureRatio = o.statistics()
+			s.State = StateName(state)
+		}
+		stats = append(stats, s)
+	}
+	g.mu.RUnlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
@@ -0,0 +1,53 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupListener(t *testing.T) {
+	g := NewGroup(&Config{
+		Type:           TypeClassic,
+		Request:        10,
+		OpenDuration:   100 * time.Millisecond,
+		HalfOpenProbes: 1,
+	})
+	var events []Event
+	g.AddListener(func(e Event) {
+		events = append(events, e)
+	})
+	b := g.Get("/helloworld.Greeter/SayHello")
+	markFailed(b, 10)
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, b.Allow())
+	b.MarkSuccess()
+
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "/helloworld.Greeter/SayHello", events[0].Key)
+	assert.Equal(t, StateClosed, events[0].From)
+	assert.Equal(t, StateOpen, events[0].To)
+	assert.Equal(t, StateHalfopen, events[1].To)
+	assert.Equal(t, StateClosed, events[2].To)
+}
+
+func TestGroupSnapshot(t *testing.T) {
+	g := NewGroup(&Config{Type: TypeClassic, Request: 10})
+	markSuccess(g.Get("a"), 3)
+	markFailed(g.Get("a"), 1)
+	markFailed(g.Get("b"), 10)
+	g.Get("c")
+
+	stats := g.Snapshot()
+	assert.Equal(t, []Stat{
+		{Key: "a", State: "closed", Requests: 4, FailureRatio: 0.25},
+		{Key: "b", State: "open", Requests: 10, FailureRatio: 1},
+		{Key: "c", State: "closed"},
+	}, stats)
+
+	sre := NewGroup(&Config{})
+	markSuccess(sre.Get("a"), 1)
+	markFailed(sre.Get("a"), 1)
+	assert.Equal(t, []Stat{{Key: "a", State: "closed", Requests: 2, FailureRatio: 0.5}}, sre.Snapshot())
+}
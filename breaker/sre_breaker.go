@@ -21,7 +21,8 @@ type sreBreaker struct {
 	k       float64
 	request int64
 
-	state int32
+	state  int32
+	notify func(from, to int32)
 }
 
 func newSRE(c *Config) Breaker {
@@ -54,29 +55,60 @@ func (b *sreBreaker) summary() (success int64, total int64) {
 	return
 }
 
-func (b *sreBreaker) Allow() error {
+// overflow reports whether the requests exceed K * success in the window.
+func (b *sreBreaker) overflow() (bool, float64, int64) {
 	success, total := b.summary()
 	k := b.k * float64(success)
+	return total >= b.request && float64(total) >= k, k, total
+}
+
+// State returns StateOpen if the breaker drops requests now.
+func (b *sreBreaker) State() int32 {
+	if overflow, _, _ := b.overflow(); overflow {
+		return StateOpen
+	}
+	return StateClosed
+}
 
+func (b *sreBreaker) Allow() error {
 	// check overflow requests = K * success
-	if total < b.request || float64(total) < k {
+	overflow, k, total := b.overflow()
+	if !overflow {
 		if atomic.LoadInt32(&b.state) == StateOpen {
-			atomic.CompareAndSwapInt32(&b.state, StateOpen, StateClosed)
+			b.transit(StateOpen, StateClosed)
 		}
 		return nil
 	}
 	if atomic.LoadInt32(&b.state) == StateClosed {
-		atomic.CompareAndSwapInt32(&b.state, StateClosed, StateOpen)
+		b.transit(StateClosed, StateOpen)
 	}
 	dr := math.Max(0, (float64(total)-k)/float64(total+1))
 	drop := b.trueOnProba(dr)
 
 	if drop {
-		return errors.ServiceUnavailable("circuit_breaker_open", "circuit breaker is open")
+		return errors.ServiceUnavailable(ReasonOpen, "circuit breaker is open")
 	}
 	return nil
 }
 
+func (b *sreBreaker) transit(from, to int32) {
+	if atomic.CompareAndSwapInt32(&b.state, from, to) && b.notify != nil {
+		b.notify(from, to)
+	}
+}
+
+func (b *sreBreaker) statistics() (state int32, requests int64, failureRatio float64) {
+	success, total := b.summary()
+	if total > 0 {
+		failureRatio = float64(total-success) / float64(total)
+	}
+	return atomic.LoadInt32(&b.state), total, failureRatio
+}
+
+func (b *sreBreaker) onStateChange(f func(from, to int32)) {
+	b.notify = f
+}
+
 func (b *sreBreaker) MarkSuccess() {
 	b.stat.Add(1)
 }
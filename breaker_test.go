@@ -0,0 +1,156 @@
+package tsf
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/breaker"
+	"github.com/tencentyun/tsf-go/pkg/sys/monitor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const breakerRule = `- targetServiceName: provider
+  targetNamespaceId: namespace-test
+  isolationLevel: SERVICE
+  strategyList:
+  - slidingWindowSize: 10
+    minimumNumberOfCalls: 10
+    failureRateThreshold: 50
+    waitDurationInOpenState: 10
+`
+
+type stubDiscovery struct {
+	registry.Discovery
+}
+
+func TestBreakerRuleSource(t *testing.T) {
+	key := "circuitbreaker/" + testNamespace + "/consumer-breaker/data"
+	fakeConsul.Put(key, []byte(breakerRule))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.ServiceUnavailable("", "")
+	}
+	client := clientContext("consumer-breaker", "provider", "/helloworld.Greeter/SayHello")
+	// 静态熔断器不会打开，请求被拒绝只能是熔断规则生效
+	static := WithBreakerConfig(&breaker.Config{Request: 1 << 30})
+	call := func(opts ...ClientOption) func() error {
+		h := middleware.Chain(clientMiddleware(), BreakerMiddleware(append(opts, static)...))(handler)
+		return func() error {
+			_, err := h(client, nil)
+			return err
+		}
+	}
+
+	// 关闭服务发现或者使用其他服务发现时不订阅consul上的熔断规则
+	for _, f := range []func() error{
+		call(WithEnableDiscovery(false)),
+		call(WithDiscovery(stubDiscovery{})),
+	} {
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, 503, int(errors.Code(f())))
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 0, fakeConsul.Queries(key))
+
+	f := call()
+	assert.Eventually(t, func() bool {
+		return errors.Reason(f()) == breaker.ReasonOpen
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, fakeConsul.Queries(key) > 0)
+}
+
+// statSink 按被调接口记录监控统计的状态码
+type statSink struct {
+	mu    sync.Mutex
+	codes map[string][]int
+}
+
+func (s *statSink) Record(stat *monitor.Stat) {
+	// sink注册后无法删除，忽略其他测试的服务端统计
+	if stat.Remote == nil {
+		return
+	}
+	s.mu.Lock()
+	s.codes[stat.Remote.InterfaceName] = append(s.codes[stat.Remote.InterfaceName], stat.StatusCode)
+	s.mu.Unlock()
+}
+
+func (s *statSink) get(operation string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codes[operation]
+}
+
+func TestBreakerFallback(t *testing.T) {
+	sink := &statSink{codes: make(map[string][]int)}
+	monitor.AddSink(sink)
+	exporter := tracetest.NewInMemoryExporter()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracesdk.NewTracerProvider(tracesdk.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(old)
+
+	var calls []string
+	fallback := func(name string, err error) BreakerFallback {
+		return func(ctx context.Context, operation string, req interface{}) (interface{}, error) {
+			calls = append(calls, name+":"+operation)
+			return name, err
+		}
+	}
+	// 一次失败就打开熔断
+	conf := &breaker.Config{Type: breaker.TypeClassic, Request: 1, FailureRatio: 0.5, OpenDuration: time.Minute}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.ServiceUnavailable("", "")
+	}
+	h := middleware.Chain(clientMiddleware(), clientMetricsMiddleware(), BreakerMiddleware(
+		WithEnableDiscovery(false),
+		WithBreakerConfig(conf),
+		WithBreakerFallback(fallback("default", nil)),
+		WithOperationFallback("/fallback/error", fallback("operation", errors.InternalServer("", ""))),
+	))(handler)
+	call := func(operation string) (interface{}, error) {
+		return h(clientContext("consumer-fallback", "provider-fallback", operation), nil)
+	}
+
+	for _, operation := range []string{"/fallback/ok", "/fallback/error"} {
+		// 请求本身失败时不执行降级
+		_, err := call(operation)
+		assert.Equal(t, 503, int(errors.Code(err)))
+		assert.Empty(t, calls)
+	}
+	// 熔断拒绝时按operation查找降级函数
+	reply, err := call("/fallback/ok")
+	assert.Nil(t, err)
+	assert.Equal(t, "default", reply)
+	_, err = call("/fallback/error")
+	assert.Equal(t, 500, int(errors.Code(err)))
+	assert.Equal(t, []string{"default:/fallback/ok", "operation:/fallback/error"}, calls)
+
+	// 原请求按熔断拒绝统计，降级单独统计
+	assert.Equal(t, []int{503, monitor.StatusBreakerOpen}, sink.get("/fallback/ok"))
+	assert.Equal(t, []int{200}, sink.get("/fallback/ok"+fallbackSuffix))
+	assert.Equal(t, []int{503, monitor.StatusBreakerOpen}, sink.get("/fallback/error"))
+	assert.Equal(t, []int{500}, sink.get("/fallback/error"+fallbackSuffix))
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		for i, operation := range []string{"/fallback/ok", "/fallback/error"} {
+			assert.Equal(t, operation+fallbackSuffix, spans[i].Name)
+			var cause string
+			for _, attr := range spans[i].Attributes {
+				if attr.Key == attribute.Key("fallback.cause") {
+					cause = attr.Value.AsString()
+				}
+			}
+			assert.Contains(t, cause, "circuit breaker is open")
+		}
+	}
+}
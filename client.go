@@ -8,12 +8,13 @@ import (
 	"github.com/tencentyun/tsf-go/balancer"
 	"github.com/tencentyun/tsf-go/balancer/p2c"
 	"github.com/tencentyun/tsf-go/breaker"
+	"github.com/tencentyun/tsf-go/fault"
 	"github.com/tencentyun/tsf-go/grpc/balancer/multi"
 	httpMulti "github.com/tencentyun/tsf-go/http/balancer/multi"
 	"github.com/tencentyun/tsf-go/naming/consul"
+	"github.com/tencentyun/tsf-go/pkg/config"
 	"github.com/tencentyun/tsf-go/pkg/meta"
 	"github.com/tencentyun/tsf-go/pkg/sys/env"
-	"github.com/tencentyun/tsf-go/route/composite"
 	"github.com/tencentyun/tsf-go/route/lane"
 	"github.com/tencentyun/tsf-go/tracing"
 	"github.com/tencentyun/tsf-go/util"
@@ -22,6 +23,7 @@ import (
 	"github.com/go-kratos/kratos/v2/metadata"
 	"github.com/go-kratos/kratos/v2/middleware"
 	mmeta "github.com/go-kratos/kratos/v2/middleware/metadata"
+	"github.com/go-kratos/kratos/v2/registry"
 	"github.com/go-kratos/kratos/v2/transport"
 	tgrpc "github.com/go-kratos/kratos/v2/transport/grpc"
 	"github.com/go-kratos/kratos/v2/transport/http"
@@ -31,11 +33,26 @@ import (
 type ClientOption func(*clientOpionts)
 
 type clientOpionts struct {
-	breakerCfg       *breaker.Config
-	breakerErrorHook func(ctx context.Context, operation string, err error) (success bool)
-	m                []middleware.Middleware
-	balancer         balancer.Balancer
-	enableDiscovery  bool
+	breakerCfg         *breaker.Config
+	breakerErrorHook   func(ctx context.Context, operation string, err error) (success bool)
+	breakerFallback    BreakerFallback
+	breakerRuleSource  config.Source
+	operationFallbacks map[string]BreakerFallback
+	retryCfg           *RetryConfig
+	m                  []middleware.Middleware
+	balancer           balancer.Balancer
+	enableDiscovery    bool
+	discovery          registry.Discovery
+	errorDecoder       http.DecodeErrorFunc
+	fault              bool
+	faultSource        config.Source
+}
+
+// WithDiscovery sets the service discovery, default is consul.
+func WithDiscovery(d registry.Discovery) ClientOption {
+	return func(o *clientOpionts) {
+		o.discovery = d
+	}
 }
 
 func WithEnableDiscovery(enableDiscovery bool) ClientOption {
@@ -62,6 +79,14 @@ func WithMiddlewares(m ...middleware.Middleware) ClientOption {
 	}
 }
 
+// WithErrorDecoder sets the http error decoder, use it instead of http.WithErrorDecoder
+// so that the server load reported in response header is still collected for the balancer.
+func WithErrorDecoder(d http.DecodeErrorFunc) ClientOption {
+	return func(o *clientOpionts) {
+		o.errorDecoder = d
+	}
+}
+
 func WithBalancer(b balancer.Balancer) ClientOption {
 	return func(o *clientOpionts) {
 		o.balancer = b
@@ -113,7 +138,7 @@ func startClientContext(ctx context.Context, remoteServiceName string, l *lane.L
 }
 
 func clientMiddleware() middleware.Middleware {
-	router := composite.DefaultComposite()
+	router := useDefaultComposite()
 	lane := router.Lane()
 	var remoteServiceName string
 	var once sync.Once
@@ -132,14 +157,44 @@ func clientMiddleware() middleware.Middleware {
 	}
 }
 
-// ClientMiddleware is client middleware
-func ClientMiddleware() middleware.Middleware {
-	return middleware.Chain(clientMiddleware(), tracingClient(), clientMetricsMiddleware(), mmeta.Client())
+// middlewares returns the client middleware chain,
+// retry is placed before tracing and metrics so that every attempt is recorded,
+// fault injection is placed after user middlewares so that breaker sees the injected faults.
+func (o *clientOpionts) middlewares() []middleware.Middleware {
+	m := []middleware.Middleware{clientMiddleware()}
+	if o.retryCfg != nil {
+		m = append(m, retryMiddleware(o.retryCfg))
+	}
+	m = append(m, tracingClient(), clientMetricsMiddleware(), mmeta.Client())
+	m = append(m, o.m...)
+	if o.fault {
+		m = append(m, faultMiddleware(fault.SideClient, o.faultSource))
+	}
+	return m
+}
+
+func (o *clientOpionts) getDiscovery() registry.Discovery {
+	if o.discovery != nil {
+		return o.discovery
+	}
+	return consul.DefaultConsul()
+}
+
+// ClientMiddleware is client middleware, only WithClientFaultInjection of the options takes effect.
+func ClientMiddleware(opts ...ClientOption) middleware.Middleware {
+	var o clientOpionts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := []middleware.Middleware{clientMiddleware(), tracingClient(), clientMetricsMiddleware(), mmeta.Client()}
+	if o.fault {
+		m = append(m, faultMiddleware(fault.SideClient, o.faultSource))
+	}
+	return middleware.Chain(m...)
 }
 
 func ClientGrpcOptions(copts ...ClientOption) []tgrpc.ClientOption {
 	var o clientOpionts = clientOpionts{
-		m:               []middleware.Middleware{clientMiddleware(), tracingClient(), clientMetricsMiddleware(), mmeta.Client()},
 		enableDiscovery: true,
 		balancer:        p2c.New(nil),
 		//balancer: random.New(),
@@ -151,22 +206,22 @@ func ClientGrpcOptions(copts ...ClientOption) []tgrpc.ClientOption {
 
 	var opts []tgrpc.ClientOption
 	// 将负载均衡模块注册至grpc
-	multi.Register(composite.DefaultComposite(), o.balancer)
+	multi.Register(useDefaultComposite(), o.balancer)
 	opts = []tgrpc.ClientOption{
 		tgrpc.WithOptions(grpc.WithBalancerName(o.balancer.Schema()), grpc.WithStatsHandler(&tracing.ClientHandler{})),
-		tgrpc.WithMiddleware(o.m...),
+		tgrpc.WithMiddleware(o.middlewares()...),
 	}
 	if o.enableDiscovery {
-		opts = append(opts, tgrpc.WithDiscovery(consul.DefaultConsul()))
+		opts = append(opts, tgrpc.WithDiscovery(o.getDiscovery()))
 	}
 	return opts
 }
 
 func ClientHTTPOptions(copts ...ClientOption) []http.ClientOption {
 	var o clientOpionts = clientOpionts{
-		m:               []middleware.Middleware{clientMiddleware(), tracingClient(), clientMetricsMiddleware(), mmeta.Client()},
 		enableDiscovery: true,
 		balancer:        p2c.New(nil),
+		errorDecoder:    http.DefaultErrorDecoder,
 		//balancer: random.New(),
 		//balancer: hash.New(),
 	}
@@ -175,12 +230,15 @@ func ClientHTTPOptions(copts ...ClientOption) []http.ClientOption {
 	}
 
 	var opts []http.ClientOption
+	b := httpMulti.New(useDefaultComposite(), o.balancer)
 	opts = []http.ClientOption{
-		http.WithBalancer(httpMulti.New(composite.DefaultComposite(), o.balancer)),
-		http.WithMiddleware(o.m...),
+		http.WithBalancer(b),
+		// 从响应头中收集服务端上报的负载信息
+		http.WithErrorDecoder(b.ErrorDecoder(o.errorDecoder)),
+		http.WithMiddleware(o.middlewares()...),
 	}
 	if o.enableDiscovery {
-		opts = append(opts, http.WithDiscovery(consul.DefaultConsul()))
+		opts = append(opts, http.WithDiscovery(o.getDiscovery()))
 	}
 	return opts
 }
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+var _ Decryptor = &AESGCM{}
+
+// AESGCM encrypts and decrypts the config values by AES-GCM,
+// the ciphertext is base64 encoded nonce followed by the sealed data.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM new an AES-GCM decryptor, the key should be 16, 24 or 32 bytes.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// Encrypt encrypts the plaintext, the result can be written to config as ENC(result).
+func (a *AESGCM) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := a.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (a *AESGCM) Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < a.aead.NonceSize() {
+		return "", errors.New("aes-gcm: ciphertext too short")
+	}
+	nonce, sealed := sealed[:a.aead.NonceSize()], sealed[a.aead.NonceSize():]
+	plain, err := a.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tencentyun/tsf-go/log"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Validator is implemented by the bound struct to validate the config.
+type Validator interface {
+	Validate() error
+}
+
+// Binding holds the typed config which is updated on every config push.
+type Binding struct {
+	typ   reflect.Type
+	proto interface{}
+	opts  bindOptions
+
+	mu    sync.Mutex
+	conf  *Config
+	ready bool
+	value atomic.Value
+}
+
+type bindOptions struct {
+	validators []func(v interface{}) error
+	onChange   []func(v interface{}, changed []string)
+}
+
+// BindOption is config binding option.
+type BindOption func(*bindOptions)
+
+// WithValidator adds a validation hook, the config is not updated if validation failed.
+func WithValidator(f func(v interface{}) error) BindOption {
+	return func(o *bindOptions) {
+		o.validators = append(o.validators, f)
+	}
+}
+
+// WithOnChange is called with the new config and the yaml paths of changed fields, e.g. auth.key
+func WithOnChange(f func(v interface{}, changed []string)) BindOption {
+	return func(o *bindOptions) {
+		o.onChange = append(o.onChange, f)
+	}
+}
+
+// Bind binds the merged config (see WithMerged) to the struct pointed by v,
+// the fields are decoded by yaml tags, and the values already set in v or by default tags are used as defaults.
+// The bound value is filled into v once, use Load to get the latest value.
+func Bind(v interface{}, opts ...BindOption) (*Binding, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: Bind requires a pointer to struct, got %T", v)
+	}
+	if err := setDefaults(rv.Elem()); err != nil {
+		return nil, err
+	}
+	b := &Binding{typ: rv.Elem().Type(), proto: rv.Elem().Interface()}
+	for _, o := range opts {
+		o(&b.opts)
+	}
+	// 首次推送在WatchConfig中同步执行，只记录配置，注册完成后统一解析一次
+	WatchConfig(func(conf *Config) {
+		b.mu.Lock()
+		b.conf = conf
+		ready := b.ready
+		b.mu.Unlock()
+		if ready {
+			b.reload()
+		}
+	}, WithMerged(true))
+	b.mu.Lock()
+	b.ready = true
+	b.mu.Unlock()
+	if err := b.reload(); err != nil && b.Load() == nil {
+		return nil, err
+	}
+	rv.Elem().Set(reflect.ValueOf(b.Load()).Elem())
+	return b, nil
+}
+
+// Load returns the latest config, which is a pointer to the bound struct type and should not be modified.
+func (b *Binding) Load() interface{} {
+	return b.value.Load()
+}
+
+func (b *Binding) reload() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer func() {
+		if err != nil {
+			log.DefaultLog.Errorw("msg", "[config] bind config failed, keep the old value!", "type", b.typ.String(), "err", err)
+		}
+	}()
+	v, err := b.decode()
+	if err != nil {
+		return
+	}
+	if validator, ok := v.(Validator); ok {
+		if err = validator.Validate(); err != nil {
+			return
+		}
+	}
+	for _, f := range b.opts.validators {
+		if err = f(v); err != nil {
+			return
+		}
+	}
+	old := b.value.Load()
+	b.value.Store(v)
+	if old == nil {
+		return
+	}
+	changed := diff("", reflect.ValueOf(old).Elem(), reflect.ValueOf(v).Elem())
+	if len(changed) == 0 {
+		return
+	}
+	for _, f := range b.opts.onChange {
+		f(v, changed)
+	}
+	return
+}
+
+// decode decodes the merged config over a copy of defaults.
+func (b *Binding) decode() (interface{}, error) {
+	v := reflect.New(b.typ)
+	// 通过yaml深拷贝默认值，避免解析配置时修改默认值中的map
+	raw, err := yaml.Marshal(b.proto)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, err
+	}
+	if err = b.conf.Unmarshal(v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// diff returns the yaml paths of changed fields.
+func diff(prefix string, old, new reflect.Value) (changed []string) {
+	if old.Kind() != reflect.Struct || old.Type() == reflect.TypeOf(time.Time{}) {
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			changed = append(changed, prefix)
+		}
+		return
+	}
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		changed = append(changed, diff(name, old.Field(i), new.Field(i))...)
+	}
+	return
+}
+
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		// 与yaml默认的字段名保持一致
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// setDefaults sets the zero fields by the default tag, e.g. `default:"1s"`
+func setDefaults(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		fv := v.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if fv.Kind() == reflect.Struct {
+			if err := setDefaults(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			continue
+		}
+		if err := setValue(fv, def); err != nil {
+			return fmt.Errorf("config: invalid default value of field %s: %v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return yaml.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
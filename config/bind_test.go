@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type server struct {
+	Port    int           `yaml:"port"`
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   int           `yaml:"retry" default:"3"`
+}
+
+type appConfig struct {
+	Name   string `yaml:"name"`
+	Server server `yaml:"server"`
+	Debug  bool   `yaml:"debug" default:"true"`
+}
+
+func (c *appConfig) Validate() error {
+	if c.Server.Port <= 0 {
+		return errors.New("invalid port")
+	}
+	return nil
+}
+
+func TestGetter(t *testing.T) {
+	port, ok := GetInt("server.port")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8080), port)
+	s, ok := GetString("server.port")
+	assert.True(t, ok)
+	assert.Equal(t, "8080", s)
+	weight, ok := GetInt("hosts.0.weight")
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), weight)
+	timeout, ok := GetDuration("server.timeout", WithGlobal(true))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, timeout)
+	_, ok = GetInt("hosts.1.weight")
+	assert.False(t, ok)
+}
+
+func TestBind(t *testing.T) {
+	changes := make(chan []string, 1)
+	var conf appConfig
+	b, err := Bind(&conf, WithOnChange(func(v interface{}, changed []string) {
+		changes <- changed
+	}))
+	assert.Nil(t, err)
+	// app覆盖global
+	assert.Equal(t, "application", conf.Name)
+	assert.Equal(t, 8080, conf.Server.Port)
+	assert.Equal(t, time.Second, conf.Server.Timeout)
+	assert.Equal(t, 3, conf.Server.Retry)
+	assert.True(t, conf.Debug)
+
+	mem.Put(appKey, []byte(`
+server:
+  port: 9090
+  retry: 5
+`))
+	select {
+	case changed := <-changes:
+		assert.Equal(t, []string{"server.port", "server.retry"}, changed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bind timeout")
+	}
+	assert.Equal(t, 9090, b.Load().(*appConfig).Server.Port)
+
+	// 校验失败保留旧值
+	mem.Put(appKey, []byte(`
+server:
+  port: -1
+`))
+	assert.Eventually(t, func() bool {
+		port, _ := GetInt("server.port")
+		return port == -1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 9090, b.Load().(*appConfig).Server.Port)
+	assert.Equal(t, 0, len(changes))
+
+	var invalid appConfig
+	_, err = Bind(&invalid, WithValidator(func(v interface{}) error {
+		if v.(*appConfig).Server.Port < 0 {
+			return errors.New("negative port")
+		}
+		return nil
+	}))
+	assert.NotNil(t, err)
+}
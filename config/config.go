@@ -1,10 +1,16 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tencentyun/tsf-go/log"
 	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/config/memory"
+
+	"gopkg.in/yaml.v3"
 )
 
 var _ config.Config = &Config{}
@@ -13,6 +19,8 @@ var _ config.Config = &Config{}
 type Config struct {
 	v map[string]interface{}
 	config.Data
+	// 解密ENC(...)后的配置，Raw仍然返回原始配置
+	plain config.Data
 }
 
 func newTsfConfig(d config.Data) *Config {
@@ -27,64 +35,171 @@ func (c *Config) Get(key string) (v interface{}, ok bool) {
 
 func (c *Config) GetString(key string) (v string, ok bool) {
 	res, ok := c.get(key)
-	if ok {
-		v, ok = res.(string)
+	if !ok {
+		return
+	}
+	switch t := res.(type) {
+	case string:
+		v = t
+	case int, int64, uint64, float64, bool:
+		v = fmt.Sprint(t)
+	default:
+		ok = false
 	}
 	return
 }
 
 func (c *Config) GetBool(key string) (v bool, ok bool) {
 	res, ok := c.get(key)
-	if ok {
-		v, ok = res.(bool)
+	if !ok {
+		return
+	}
+	switch t := res.(type) {
+	case bool:
+		v = t
+	case string:
+		var err error
+		v, err = strconv.ParseBool(t)
+		ok = err == nil
+	default:
+		ok = false
 	}
 	return
 }
 
+// GetInt yaml会将整数解析为int，这里统一转换为int64
 func (c *Config) GetInt(key string) (v int64, ok bool) {
 	res, ok := c.get(key)
-	if ok {
-		v, ok = res.(int64)
+	if !ok {
+		return
+	}
+	switch t := res.(type) {
+	case int:
+		v = int64(t)
+	case int64:
+		v = t
+	case uint64:
+		v = int64(t)
+	case float64:
+		v = int64(t)
+		ok = float64(v) == t
+	case string:
+		var err error
+		v, err = strconv.ParseInt(t, 10, 64)
+		ok = err == nil
+	default:
+		ok = false
 	}
 	return
 }
 
 func (c *Config) GetFloat(key string) (v float64, ok bool) {
 	res, ok := c.get(key)
-	if ok {
-		v, ok = res.(float64)
+	if !ok {
+		return
+	}
+	switch t := res.(type) {
+	case float64:
+		v = t
+	case int:
+		v = float64(t)
+	case int64:
+		v = float64(t)
+	case uint64:
+		v = float64(t)
+	case string:
+		var err error
+		v, err = strconv.ParseFloat(t, 64)
+		ok = err == nil
+	default:
+		ok = false
 	}
 	return
 }
 
+// GetDuration 支持"1s"这样的字符串，整数和yaml一样当作纳秒处理
 func (c *Config) GetDuration(key string) (v time.Duration, ok bool) {
 	res, ok := c.get(key)
-	if ok {
-		v, ok = res.(time.Duration)
+	if !ok {
+		return
+	}
+	switch t := res.(type) {
+	case time.Duration:
+		v = t
+	case string:
+		var err error
+		v, err = time.ParseDuration(t)
+		ok = err == nil
+	default:
+		var n int64
+		if n, ok = c.GetInt(key); ok {
+			v = time.Duration(n)
+		}
 	}
 	return
 }
 
 func (c *Config) GetTime(key string) (v time.Time, ok bool) {
 	res, ok := c.get(key)
-	if ok {
-		v, ok = res.(time.Time)
+	if !ok {
+		return
+	}
+	switch t := res.(type) {
+	case time.Time:
+		v = t
+	case string:
+		var err error
+		v, err = time.Parse(time.RFC3339, t)
+		ok = err == nil
+	default:
+		ok = false
 	}
 	return
 }
 
+// get 支持a.b.c这样的路径访问嵌套的配置，数组可以使用下标访问，比如a.0.b
 func (c *Config) get(key string) (res interface{}, ok bool) {
 	if c == nil {
 		return
 	}
-	res, ok = c.v[key]
-	return
+	if res, ok = c.v[key]; ok {
+		return
+	}
+	paths := strings.Split(key, ".")
+	if len(paths) == 1 {
+		return
+	}
+	res = c.v
+	for _, path := range paths {
+		switch t := res.(type) {
+		case map[string]interface{}:
+			if res, ok = t[path]; !ok {
+				return
+			}
+		case map[interface{}]interface{}:
+			if res, ok = t[path]; !ok {
+				return
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(path)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			res = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return res, true
 }
 
 func (c *Config) Unmarshal(v interface{}) error {
 	if c == nil || c.Data == nil {
 		return nil
 	}
+	if c.plain != nil {
+		return c.plain.Unmarshal(v)
+	}
 	return c.Data.Unmarshal(v)
 }
 
@@ -95,9 +210,64 @@ func (c *Config) Raw() []byte {
 	return c.Data.Raw()
 }
 
+// mergeConfig 按顺序合并配置，后面的配置覆盖前面的，嵌套的map会递归合并
+// 合并的是原始配置，合并后再统一解密
+func mergeConfig(confs ...*Config) *Config {
+	var res map[string]interface{}
+	for _, c := range confs {
+		if c == nil || c.Data == nil {
+			continue
+		}
+		if res == nil {
+			res = make(map[string]interface{})
+		}
+		v := make(map[string]interface{})
+		if err := c.Data.Unmarshal(v); err != nil {
+			continue
+		}
+		mergeMap(res, v)
+	}
+	if res == nil {
+		return nil
+	}
+	raw, err := yaml.Marshal(res)
+	if err != nil {
+		log.DefaultLog.Errorw("msg", "config merge failed!", "err", err)
+	}
+	return newTsfConfig(memory.Raw(raw))
+}
+
+func mergeMap(dst, src map[string]interface{}) {
+	for k, v := range src {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		// dst中的map都是新建的，不会修改原配置
+		sub, ok := dst[k].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{}, len(m))
+		}
+		mergeMap(sub, m)
+		dst[k] = sub
+	}
+}
+
+// refill 解析配置并解密ENC(...)的值，日志中不能打印配置内容
 func (c *Config) refill() {
 	err := c.Data.Unmarshal(c.v)
 	if err != nil {
-		log.DefaultLog.Errorw("msg", "config refill failed!", "err", err, "raw", string(c.Raw()))
+		log.DefaultLog.Errorw("msg", "config refill failed!", "err", err, "size", len(c.Raw()))
+		return
+	}
+	if _, ok := decrypt("", c.v); !ok {
+		return
+	}
+	plain, err := yaml.Marshal(c.v)
+	if err != nil {
+		log.DefaultLog.Errorw("msg", "config marshal decrypted failed!", "err", err)
+		return
 	}
+	c.plain = memory.Raw(plain)
 }
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+)
+
+// Decryptor decrypts the config values in ENC(...) syntax.
+type Decryptor interface {
+	Decrypt(ciphertext string) (string, error)
+}
+
+var decryptorMu sync.RWMutex
+var decryptor Decryptor
+var decryptorOnce sync.Once
+
+// RegisterDecryptor 注册ENC(...)配置值的解密器，需要在Init之前调用
+// 默认使用环境变量tsf_config_encrypt_key或tsf_config_encrypt_key_file中的AES-GCM密钥
+func RegisterDecryptor(d Decryptor) {
+	decryptorMu.Lock()
+	decryptor = d
+	decryptorMu.Unlock()
+}
+
+func getDecryptor() Decryptor {
+	decryptorOnce.Do(func() {
+		decryptorMu.Lock()
+		defer decryptorMu.Unlock()
+		if decryptor != nil {
+			return
+		}
+		key, err := env.ConfigEncryptKey()
+		if err != nil {
+			log.DefaultLog.Errorw("msg", "load config encrypt key failed!", "err", err)
+			return
+		}
+		if len(key) == 0 {
+			return
+		}
+		if decryptor, err = NewAESGCM(key); err != nil {
+			log.DefaultLog.Errorw("msg", "new config decryptor failed!", "err", err)
+		}
+	})
+	decryptorMu.RLock()
+	defer decryptorMu.RUnlock()
+	return decryptor
+}
+
+// decrypt 解密配置中所有ENC(...)格式的字符串，返回是否有值被解密
+// 解密失败时保留原值，日志中只打印key，避免泄露配置内容
+func decrypt(path string, v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case string:
+		if !strings.HasPrefix(t, "ENC(") || !strings.HasSuffix(t, ")") {
+			return t, false
+		}
+		d := getDecryptor()
+		if d == nil {
+			log.DefaultLog.Errorw("msg", "config decrypt failed, no decryptor registered!", "key", path)
+			return t, false
+		}
+		plain, err := d.Decrypt(t[len("ENC(") : len(t)-1])
+		if err != nil {
+			log.DefaultLog.Errorw("msg", "config decrypt failed!", "key", path, "err", err)
+			return t, false
+		}
+		return plain, true
+	case map[string]interface{}:
+		var decrypted bool
+		for k, sub := range t {
+			var ok bool
+			if t[k], ok = decrypt(join(path, k), sub); ok {
+				decrypted = true
+			}
+		}
+		return t, decrypted
+	case []interface{}:
+		var decrypted bool
+		for i, sub := range t {
+			var ok bool
+			if t[i], ok = decrypt(join(path, strconv.Itoa(i)), sub); ok {
+				decrypted = true
+			}
+		}
+		return t, decrypted
+	}
+	return v, false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/config/memory"
+)
+
+func TestAESGCM(t *testing.T) {
+	a, err := NewAESGCM([]byte("0123456789abcdef"))
+	assert.Nil(t, err)
+	ciphertext, err := a.Encrypt("secret")
+	assert.Nil(t, err)
+	plain, err := a.Decrypt(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", plain)
+
+	b, err := NewAESGCM([]byte("fedcba9876543210"))
+	assert.Nil(t, err)
+	_, err = b.Decrypt(ciphertext)
+	assert.NotNil(t, err)
+	_, err = NewAESGCM([]byte("short"))
+	assert.NotNil(t, err)
+}
+
+func TestDecrypt(t *testing.T) {
+	a, err := NewAESGCM([]byte("0123456789abcdef"))
+	assert.Nil(t, err)
+	RegisterDecryptor(a)
+	password, err := a.Encrypt("secret")
+	assert.Nil(t, err)
+	raw := fmt.Sprintf(`
+db:
+  password: ENC(%s)
+  user: root
+tokens:
+- ENC(%s)
+- ENC(invalid)
+`, password, password)
+	c := newTsfConfig(memory.Raw(raw))
+	v, _ := c.GetString("db.password")
+	assert.Equal(t, "secret", v)
+	v, _ = c.GetString("tokens.0")
+	assert.Equal(t, "secret", v)
+	// 解密失败保留原值
+	v, _ = c.GetString("tokens.1")
+	assert.Equal(t, "ENC(invalid)", v)
+
+	var conf struct {
+		DB struct {
+			User     string `yaml:"user"`
+			Password string `yaml:"password"`
+		} `yaml:"db"`
+	}
+	assert.Nil(t, c.Unmarshal(&conf))
+	assert.Equal(t, "secret", conf.DB.Password)
+	assert.Equal(t, "root", conf.DB.User)
+	// Raw返回原始的加密配置
+	assert.Equal(t, raw, string(c.Raw()))
+
+	merged := mergeConfig(c, newTsfConfig(memory.Raw("db:\n  user: admin\n")))
+	v, _ = merged.GetString("db.password")
+	assert.Equal(t, "secret", v)
+	v, _ = merged.GetString("db.user")
+	assert.Equal(t, "admin", v)
+	assert.NotContains(t, string(merged.Raw()), "secret")
+}
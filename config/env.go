@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/config/memory"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量覆盖配置的前缀，优先级高于其他所有配置，只在Init时加载一次
+// 去掉前缀后转为小写，双下划线表示嵌套的key，例如:
+// TSF_CONFIG_NAME=demo 覆盖 name
+// TSF_CONFIG_DB__DSN=root@tcp(127.0.0.1:3306)/demo 覆盖 db.dsn
+// TSF_CONFIG_SERVER__READ_TIMEOUT=1s 覆盖 server.read_timeout
+// 值按yaml标量解析，8080、true会被解析为数字和bool，不支持覆盖数组中的元素
+const EnvPrefix = "TSF_CONFIG_"
+
+// envConfig 从KEY=value形式的环境变量中构建覆盖配置，没有覆盖时返回nil
+func envConfig(environ []string) *Config {
+	var res map[string]interface{}
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			continue
+		}
+		keys := strings.Split(strings.ToLower(kv[len(EnvPrefix):i]), "__")
+		if !validKeys(keys) {
+			log.DefaultLog.Errorw("msg", "invalid config env, ignore it!", "env", kv[:i])
+			continue
+		}
+		if res == nil {
+			res = make(map[string]interface{})
+		}
+		m := res
+		for _, key := range keys[:len(keys)-1] {
+			sub, ok := m[key].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				m[key] = sub
+			}
+			m = sub
+		}
+		m[keys[len(keys)-1]] = envValue(kv[i+1:])
+	}
+	if res == nil {
+		return nil
+	}
+	raw, err := yaml.Marshal(res)
+	if err != nil {
+		log.DefaultLog.Errorw("msg", "marshal config env failed!", "err", err)
+		return nil
+	}
+	return newTsfConfig(memory.Raw(raw))
+}
+
+func validKeys(keys []string) bool {
+	for _, key := range keys {
+		if key == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// envValue 按yaml标量解析，解析失败或者不是标量时保留原始字符串
+func envValue(s string) interface{} {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	switch v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return s
+	}
+	return v
+}
@@ -3,12 +3,16 @@ package config
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/tencentyun/tsf-go/log"
 	"github.com/tencentyun/tsf-go/pkg/config"
 	"github.com/tencentyun/tsf-go/pkg/config/consul"
+	"github.com/tencentyun/tsf-go/pkg/config/memory"
 	"github.com/tencentyun/tsf-go/pkg/sys/env"
 	"github.com/tencentyun/tsf-go/pkg/util"
 )
@@ -16,17 +20,40 @@ import (
 var mu sync.RWMutex
 var once sync.Once
 
+// 配置按优先级从低到高: 命名空间全局配置、应用配置、部署组配置、本地覆盖配置、环境变量覆盖配置
 var global *Config
+var application *Config
 var app *Config
+var local *Config
+var environ *Config
+var merged *Config
 
 var globalFunc []func(conf *Config)
 var appFunc []func(conf *Config)
+var mergedFunc []func(conf *Config)
+var keyFunc = make(map[string][]func(v interface{}, ok bool))
+
+var source config.Source
+
+// SetSource 设置配置的数据源，需要在Init之前调用，默认使用consul
+// 本地开发时可以使用file或memory数据源
+func SetSource(s config.Source) {
+	mu.Lock()
+	source = s
+	mu.Unlock()
+}
 
 // Init 需要提前初始化，否则可能获取不到数据
 func Init() {
 	util.ParseFlag()
-	source := consul.DefaultConsul()
+	mu.RLock()
+	source := source
+	mu.RUnlock()
+	if source == nil {
+		source = consul.DefaultConsul()
+	}
 	appWatcher := source.Subscribe(fmt.Sprintf("config/application/%s/%s/data", env.ApplicationID(), env.GroupID()))
+	applicationWatcher := source.Subscribe(fmt.Sprintf("config/application/%s/data", env.ApplicationID()))
 	globalWatcher := source.Subscribe(fmt.Sprintf("config/application/%s/data", env.NamespaceID()))
 
 	appSpecs, err := appWatcher.Watch(context.Background())
@@ -34,65 +61,102 @@ func Init() {
 		log.DefaultLog.Errorf("config watch failed!err:=%v", err)
 		return
 	}
+	applicationSpecs, err := applicationWatcher.Watch(context.Background())
+	if err != nil {
+		log.DefaultLog.Errorf("config watch failed!err:=%v", err)
+		return
+	}
 	gloablSpecs, err := globalWatcher.Watch(context.Background())
 	if err != nil {
 		log.DefaultLog.Errorf("config watch failed!err:=%v", err)
 		return
 	}
+	mu.Lock()
 	if len(appSpecs) > 0 {
 		app = newTsfConfig(appSpecs[0].Data)
 	}
+	if len(applicationSpecs) > 0 {
+		application = newTsfConfig(applicationSpecs[0].Data)
+	}
 	if len(gloablSpecs) > 0 {
 		global = newTsfConfig(gloablSpecs[0].Data)
 	}
+	if file := env.ConfigFile(); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.DefaultLog.Errorw("msg", "read local config file failed!", "file", file, "err", err)
+		} else {
+			local = newTsfConfig(memory.Raw(content))
+		}
+	}
+	environ = envConfig(os.Environ())
+	merged = mergeConfig(global, application, app, local, environ)
+	mu.Unlock()
 
-	go refreshApp(appWatcher)
-	go refreshGlobal(globalWatcher)
+	go refresh("refreshApp", appWatcher, &app, &appFunc)
+	go refresh("refreshApplication", applicationWatcher, &application, nil)
+	go refresh("refreshGlobal", globalWatcher, &global, &globalFunc)
 }
 
-func refreshGlobal(globalWatcher config.Watcher) {
+// refresh 更新某一层配置，并重新合并配置
+func refresh(name string, watcher config.Watcher, layer **Config, funcs *[]func(conf *Config)) {
 	ctx := context.Background()
 	for {
-		specs, err := globalWatcher.Watch(ctx)
+		specs, err := watcher.Watch(ctx)
 		if err != nil {
-			log.DefaultLog.Errorw("msg", "refreshGlobal Watch failed!", "err", err)
+			log.DefaultLog.Errorw("msg", name+" Watch failed!", "err", err)
 			return
 		}
 		var conf *Config
 		if len(specs) > 0 {
 			conf = newTsfConfig(specs[0].Data)
 		}
+		var layerFuncs []func(conf *Config)
 		mu.Lock()
-		global = conf
+		*layer = conf
+		if funcs != nil {
+			layerFuncs = *funcs
+		}
+		notify := remerge()
 		mu.Unlock()
 		go func() {
-			for _, f := range globalFunc {
+			for _, f := range layerFuncs {
 				f(conf)
 			}
+			notify()
 		}()
 	}
 }
 
-func refreshApp(appWatcher config.Watcher) {
-	ctx := context.Background()
-	for {
-		specs, err := appWatcher.Watch(ctx)
-		if err != nil {
-			log.DefaultLog.Errorw("msg", "refreshApp Watch failed!", "err", err)
-			return
+// remerge 需要持有锁调用，返回通知订阅者的函数
+func remerge() func() {
+	old := merged
+	conf := mergeConfig(global, application, app, local, environ)
+	merged = conf
+	funcs := mergedFunc
+	type change struct {
+		v  interface{}
+		ok bool
+		fs []func(v interface{}, ok bool)
+	}
+	var changes []change
+	for key, fs := range keyFunc {
+		oldV, oldOk := old.get(key)
+		v, ok := conf.get(key)
+		if oldOk == ok && reflect.DeepEqual(oldV, v) {
+			continue
 		}
-		var conf *Config
-		if len(specs) > 0 {
-			conf = newTsfConfig(specs[0].Data)
+		changes = append(changes, change{v: v, ok: ok, fs: fs})
+	}
+	return func() {
+		for _, f := range funcs {
+			f(conf)
 		}
-		mu.Lock()
-		app = conf
-		mu.Unlock()
-		go func() {
-			for _, f := range appFunc {
-				f(conf)
+		for _, c := range changes {
+			for _, f := range c.fs {
+				f(c.v, c.ok)
 			}
-		}()
+		}
 	}
 }
 
@@ -109,20 +173,36 @@ func WatchConfig(f func(conf *Config), opts ...Option) {
 	for _, o := range opts {
 		o(&opt)
 	}
+	// 注册后释放锁再推送，回调中可以调用Get等方法
+	var conf *Config
 	mu.Lock()
-	defer mu.Unlock()
-	if opt.isGlobal {
-		if global != nil {
-			f(global)
-		}
+	if opt.isMerged {
+		conf = merged
+		mergedFunc = append(mergedFunc, f)
+	} else if opt.isGlobal {
+		conf = global
 		globalFunc = append(globalFunc, f)
-		return
+	} else {
+		conf = app
+		appFunc = append(appFunc, f)
 	}
-	if app != nil {
-		f(app)
+	mu.Unlock()
+	if conf != nil {
+		f(conf)
+	}
+}
+
+// WatchKey 订阅合并后配置中某一个key的变化，key支持a.b.c这样的路径，如果存在则第一次必推送
+// key被删除时推送ok为false
+func WatchKey(key string, f func(v interface{}, ok bool)) {
+	once.Do(Init)
+	mu.Lock()
+	v, ok := merged.get(key)
+	keyFunc[key] = append(keyFunc[key], f)
+	mu.Unlock()
+	if ok {
+		f(v, ok)
 	}
-	appFunc = append(appFunc, f)
-	return
 }
 
 func getCfg(opts ...Option) *Config {
@@ -134,7 +214,9 @@ func getCfg(opts ...Option) *Config {
 	}
 	mu.RLock()
 	defer mu.RUnlock()
-	if opt.isGlobal {
+	if opt.isMerged {
+		cfg = merged
+	} else if opt.isGlobal {
 		cfg = global
 	} else {
 		cfg = app
@@ -201,6 +283,7 @@ func GetTime(key string, opts ...Option) (v time.Time, ok bool) {
 
 type options struct {
 	isGlobal bool
+	isMerged bool
 }
 
 // WithGlobal is with global config
@@ -210,5 +293,13 @@ func WithGlobal(isGlobal bool) Option {
 	}
 }
 
+// WithMerged is with the merged config of all layers, the config of higher layer overrides the lower:
+// global < application < group < local file < environment variables(see EnvPrefix)
+func WithMerged(isMerged bool) Option {
+	return func(o *options) {
+		o.isMerged = isMerged
+	}
+}
+
 // Option is config client option.
 type Option func(*options)
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/config/memory"
+)
+
+var mem *memory.Memory
+
+const (
+	appKey         = "config/application/application-1/group-1/data"
+	applicationKey = "config/application/application-1/data"
+	globalKey      = "config/application/namespace-1/data"
+)
+
+func TestMain(m *testing.M) {
+	flag.Set("tsf_namespace_id", "namespace-1")
+	flag.Set("tsf_application_id", "application-1")
+	flag.Set("tsf_group_id", "group-1")
+	file, err := ioutil.TempFile("", "config")
+	if err != nil {
+		panic(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`
+db:
+  dsn: local
+cache:
+  addr: local
+  size: 10
+  ttl: 1m
+`)
+	file.Close()
+	flag.Set("tsf_config_file", file.Name())
+	os.Setenv("TSF_CONFIG_CACHE__ADDR", "env")
+	os.Setenv("TSF_CONFIG_CACHE__SIZE", "20")
+
+	mem = memory.New()
+	mem.Put(globalKey, []byte(`
+server:
+  timeout: 1s
+  port: 8000
+name: global
+db:
+  dsn: global
+  user: root
+`))
+	mem.Put(applicationKey, []byte(`
+name: application
+`))
+	mem.Put(appKey, []byte(`
+server:
+  port: 8080
+hosts:
+- host: 127.0.0.1
+  weight: "10"
+`))
+	SetSource(mem)
+	code := m.Run()
+	os.Remove(file.Name())
+	os.Exit(code)
+}
+
+func TestMerged(t *testing.T) {
+	name, _ := GetString("name", WithMerged(true))
+	assert.Equal(t, "application", name)
+	dsn, _ := GetString("db.dsn", WithMerged(true))
+	assert.Equal(t, "local", dsn)
+	user, _ := GetString("db.user", WithMerged(true))
+	assert.Equal(t, "root", user)
+	timeout, _ := GetDuration("server.timeout", WithMerged(true))
+	assert.Equal(t, time.Second, timeout)
+	var conf struct {
+		DB struct {
+			DSN  string `yaml:"dsn"`
+			User string `yaml:"user"`
+		} `yaml:"db"`
+	}
+	assert.Nil(t, GetConfig(WithMerged(true)).Unmarshal(&conf))
+	assert.Equal(t, "local", conf.DB.DSN)
+	assert.Equal(t, "root", conf.DB.User)
+	// 未指定时仍然是部署组配置
+	_, ok := GetString("name")
+	assert.False(t, ok)
+}
+
+func TestWatchKey(t *testing.T) {
+	values := make(chan interface{}, 10)
+	WatchKey("db.user", func(v interface{}, ok bool) {
+		if !ok {
+			v = nil
+		}
+		values <- v
+	})
+	assert.Equal(t, "root", <-values)
+
+	// 其他key变化不推送
+	mem.Put(applicationKey, []byte(`
+name: application-2
+`))
+	assert.Eventually(t, func() bool {
+		name, _ := GetString("name", WithMerged(true))
+		return name == "application-2"
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, len(values))
+
+	// 部署组配置覆盖全局配置
+	mem.Put(appKey, []byte(`
+db:
+  user: admin
+`))
+	select {
+	case v := <-values:
+		assert.Equal(t, "admin", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch key timeout")
+	}
+	mem.Delete(appKey)
+	mem.Delete(globalKey)
+	// key被删除时推送nil
+	for {
+		select {
+		case v := <-values:
+			if v == nil {
+				return
+			}
+			assert.Equal(t, "root", v)
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch key timeout")
+		}
+	}
+}
+
+func TestEnvOverride(t *testing.T) {
+	// 环境变量 > 本地覆盖配置，嵌套的key递归合并
+	addr, _ := GetString("cache.addr", WithMerged(true))
+	assert.Equal(t, "env", addr)
+	size, ok := Get("cache.size", WithMerged(true))
+	assert.True(t, ok)
+	assert.Equal(t, 20, size)
+	ttl, _ := GetDuration("cache.ttl", WithMerged(true))
+	assert.Equal(t, time.Minute, ttl)
+	// 环境变量不影响单独一层的配置
+	_, ok = GetString("cache.addr", WithGlobal(true))
+	assert.False(t, ok)
+}
+
+func TestEnvConfig(t *testing.T) {
+	assert.Nil(t, envConfig([]string{"PATH=/bin", "tsf_config_file=a.yaml"}))
+	conf := envConfig([]string{
+		"TSF_CONFIG_NAME=demo",
+		"TSF_CONFIG_DB__DSN=root@tcp(127.0.0.1:3306)/demo?a=b",
+		"TSF_CONFIG_SERVER__READ_TIMEOUT=1s",
+		"TSF_CONFIG_DEBUG=true",
+		"TSF_CONFIG_LIST=[1, 2]",
+		"TSF_CONFIG_A____B=invalid",
+		"TSF_CONFIG_=invalid",
+	})
+	expect := map[string]interface{}{
+		"name":   "demo",
+		"db":     map[string]interface{}{"dsn": "root@tcp(127.0.0.1:3306)/demo?a=b"},
+		"server": map[string]interface{}{"read_timeout": "1s"},
+		"debug":  true,
+		"list":   "[1, 2]",
+	}
+	v := make(map[string]interface{})
+	assert.Nil(t, conf.Unmarshal(v))
+	assert.Equal(t, expect, v)
+}
+
+func TestWatchKeyReentrant(t *testing.T) {
+	// 回调中调用Get不会死锁
+	values := make(chan string, 1)
+	go WatchKey("cache.addr", func(v interface{}, ok bool) {
+		addr, _ := GetString("cache.addr", WithMerged(true))
+		values <- addr
+	})
+	select {
+	case v := <-values:
+		assert.Equal(t, "env", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch key deadlock")
+	}
+}
@@ -51,7 +51,7 @@ func main() {
 	opts = append(opts, tsf.AppOptions(tsf.EnableReigstry(false))...)
 	app := kratos.New(opts...)
 
-	if err := app.Run(); err != nil {
+	if err := tsf.Run(app); err != nil {
 		log.Errorf("app run failed:%v", err)
 	}
 }
@@ -37,7 +37,7 @@ func main() {
 	opts = append(opts, tsf.AppOptions()...)
 	app := kratos.New(opts...)
 
-	if err := app.Run(); err != nil {
+	if err := tsf.Run(app); err != nil {
 		log.Println(err)
 	}
 }
@@ -47,7 +47,7 @@ func main() {
 	opts := []kratos.Option{kratos.Name("provider-grpc"), kratos.Server(grpcSrv)}
 	opts = append(opts, tsf.AppOptions()...)
 	app := kratos.New(opts...)
-	if err := app.Run(); err != nil {
+	if err := tsf.Run(app); err != nil {
 		log.Errorf("app run failed:%v", err)
 	}
 }
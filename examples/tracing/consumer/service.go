@@ -54,7 +54,7 @@ func newService(c *consul.Consul) {
 	opts = append(opts, tsf.AppOptions()...)
 	app := kratos.New(opts...)
 
-	if err := app.Run(); err != nil {
+	if err := tsf.Run(app); err != nil {
 		log.Errorf("app run failed:%v", err)
 	}
 }
@@ -0,0 +1,58 @@
+package tsf
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/tencentyun/tsf-go/fault"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/config/consul"
+	"github.com/tencentyun/tsf-go/pkg/naming"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+)
+
+// WithFaultInjection injects delays or aborts into the requests by the fault injection rules published by tsf,
+// the rules are subscribed from source, consul.DefaultConsul() is used if source is nil.
+func WithFaultInjection(source config.Source) ServerOption {
+	return func(o *serverOpionts) {
+		o.fault = true
+		o.faultSource = source
+	}
+}
+
+// WithClientFaultInjection is the client side of WithFaultInjection.
+func WithClientFaultInjection(source config.Source) ClientOption {
+	return func(o *clientOpionts) {
+		o.fault = true
+		o.faultSource = source
+	}
+}
+
+func faultMiddleware(side string, source config.Source) middleware.Middleware {
+	var injector *fault.Injector
+	var once sync.Once
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (resp interface{}, err error) {
+			once.Do(func() {
+				serviceName := env.ServiceName()
+				if k, ok := kratos.FromContext(ctx); ok {
+					serviceName = k.Name()
+				}
+				if source == nil {
+					source = consul.DefaultConsul()
+				}
+				builder := &fault.Builder{}
+				injector = builder.Build(source, naming.NewService(env.NamespaceID(), serviceName))
+				onShutdown(injector.Close)
+			})
+			// 故障注入
+			if err = injector.Inject(ctx, side); err != nil {
+				return
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package fault
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/naming"
+)
+
+// ReasonFaultInjected is the error reason returned by the aborted requests.
+const ReasonFaultInjected = "fault_injected"
+
+type Builder struct {
+}
+
+func (b *Builder) Build(cfg config.Source, svc naming.Service) *Injector {
+	watcher := cfg.Subscribe(faultKey(svc))
+	i := &Injector{
+		watcher: watcher,
+		svc:     svc,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	i.ctx, i.cancel = context.WithCancel(context.Background())
+	go i.refreshRule()
+	return i
+}
+
+// Injector injects delays or aborts into the requests hit the fault injection rules.
+type Injector struct {
+	watcher config.Watcher
+	svc     naming.Service
+
+	mu    sync.RWMutex
+	rules []Rule
+
+	// rand.New(...) returns a non thread safe object
+	randLock sync.Mutex
+	r        *rand.Rand
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Inject applies the first rule of the side hit by the request in ctx,
+// it sleeps for the injected delay and returns an error if the request is aborted.
+func (i *Injector) Inject(ctx context.Context, side string) error {
+	i.mu.RLock()
+	rules := i.rules
+	i.mu.RUnlock()
+	for _, rule := range rules {
+		if rule.Side != side || !rule.tagRule.Hit(ctx) {
+			continue
+		}
+		if rule.Delay > 0 && i.trueOnPercent(rule.DelayPercent) {
+			log.DefaultLog.WithContext(ctx).Debugw("msg", "Injector.Inject delay request!", "rule", rule.ID, "delay", rule.Delay)
+			timer := time.NewTimer(time.Duration(rule.Delay) * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if rule.AbortCode > 0 && i.trueOnPercent(rule.AbortPercent) {
+			log.DefaultLog.WithContext(ctx).Debugw("msg", "Injector.Inject abort request!", "rule", rule.ID, "code", rule.AbortCode)
+			return errors.New(rule.AbortCode, ReasonFaultInjected, fmt.Sprintf("fault injected by rule %s", rule.ID))
+		}
+		return nil
+	}
+	return nil
+}
+
+// Close stops watching the fault injection rules.
+func (i *Injector) Close() {
+	i.cancel()
+	i.watcher.Close()
+}
+
+func (i *Injector) trueOnPercent(percent float64) (truth bool) {
+	i.randLock.Lock()
+	truth = i.r.Float64()*100 < percent
+	i.randLock.Unlock()
+	return
+}
+
+func (i *Injector) refreshRule() {
+	key := faultKey(i.svc)
+	for {
+		specs, err := i.watcher.Watch(i.ctx)
+		if err != nil {
+			if errors.IsGatewayTimeout(err) || errors.IsClientClosed(err) {
+				log.DefaultLog.Errorw("msg", "watch fault injection config deadline or clsoe!exit now!", "err", err)
+				return
+			}
+			log.DefaultLog.Errorw("msg", "watch fault injection config failed!", "err", err)
+			continue
+		}
+		var faultConfigs []Config
+		for _, spec := range specs {
+			if spec.Key != key {
+				err = fmt.Errorf("found invalid fault injection config key!")
+				log.DefaultLog.Errorw("msg", "found invalid fault injection config key!", "key", spec.Key, "expect", key)
+				continue
+			}
+			err = spec.Data.Unmarshal(&faultConfigs)
+			if err != nil {
+				log.DefaultLog.Errorw("msg", "unmarshal fault injection config failed!", "err", err, "raw", string(spec.Data.Raw()))
+				continue
+			}
+		}
+		if len(faultConfigs) == 0 && err != nil {
+			log.DefaultLog.Error("get fault injection config failed,not override old data!")
+			continue
+		}
+		var rules []Rule
+		if len(faultConfigs) > 0 {
+			for _, rule := range faultConfigs[0].Rules {
+				if !rule.valid() {
+					log.DefaultLog.Errorw("msg", "found invalid fault injection rule,ignore it!", "rule", rule.ID, "side", rule.Side)
+					continue
+				}
+				rule.genTagRules()
+				rules = append(rules, rule)
+			}
+		}
+		log.DefaultLog.Infof("[fault] found new fault injection rules,replace now!rules: %v", rules)
+		i.update(rules)
+	}
+}
+
+func (i *Injector) update(rules []Rule) {
+	i.mu.Lock()
+	i.rules = rules
+	i.mu.Unlock()
+}
+
+func faultKey(svc naming.Service) string {
+	return fmt.Sprintf("faultinjection/%s/%s/data", svc.Namespace, svc.Name)
+}
@@ -0,0 +1,74 @@
+package fault
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+)
+
+func newInjector(rules ...Rule) *Injector {
+	i := &Injector{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	for idx := range rules {
+		rules[idx].genTagRules()
+	}
+	i.update(rules)
+	return i
+}
+
+func TestInjectAbort(t *testing.T) {
+	i := newInjector(Rule{
+		ID:           "fault-rule-1",
+		Side:         SideServer,
+		AbortCode:    503,
+		AbortPercent: 100,
+		Conditions:   []Tag{{Type: "U", Field: "user", Operator: "EQUAL", Value: "chaos"}},
+	})
+	hit := meta.WithUser(context.Background(), meta.UserPair{Key: "user", Value: "chaos"})
+	err := i.Inject(hit, SideServer)
+	assert.Equal(t, 503, errors.Code(err))
+	assert.Equal(t, ReasonFaultInjected, errors.Reason(err))
+
+	assert.Nil(t, i.Inject(hit, SideClient))
+	assert.Nil(t, i.Inject(context.Background(), SideServer))
+}
+
+func TestInjectDelay(t *testing.T) {
+	i := newInjector(Rule{
+		ID:           "fault-rule-2",
+		Side:         SideClient,
+		Delay:        50,
+		DelayPercent: 100,
+		Conditions:   []Tag{{Type: "S", Field: "destination.service.name", Operator: "EQUAL", Value: "provider"}},
+	})
+	ctx := meta.WithSys(context.Background(), meta.SysPair{Key: meta.DestKey(meta.ServiceName), Value: "provider"})
+	start := time.Now()
+	assert.Nil(t, i.Inject(ctx, SideClient))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// delay is interrupted by ctx
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, i.Inject(ctx, SideClient))
+}
+
+func TestInjectPercent(t *testing.T) {
+	i := newInjector(Rule{ID: "fault-rule-3", Side: SideServer, AbortCode: 500, AbortPercent: 30})
+	var aborted int
+	for n := 0; n < 10000; n++ {
+		if i.Inject(context.Background(), SideServer) != nil {
+			aborted++
+		}
+	}
+	assert.InDelta(t, 3000, aborted, 300)
+}
+
+func TestRuleValid(t *testing.T) {
+	assert.False(t, (&Rule{Side: SideServer}).valid())
+	assert.False(t, (&Rule{Side: "OTHER", AbortCode: 500, AbortPercent: 10}).valid())
+	assert.True(t, (&Rule{Side: SideClient, Delay: 10, DelayPercent: 10}).valid())
+}
@@ -0,0 +1,95 @@
+package fault
+
+import (
+	"strings"
+
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	"github.com/tencentyun/tsf-go/pkg/sys/tag"
+)
+
+// 故障注入生效的位置
+const (
+	SideServer = "SERVER"
+	SideClient = "CLIENT"
+)
+
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+type Rule struct {
+	ID   string `yaml:"ruleId"`
+	Name string `yaml:"ruleName"`
+	// SERVER 或 CLIENT
+	Side string `yaml:"side"`
+	// 注入的延迟，单位毫秒
+	Delay int64 `yaml:"delay"`
+	// 注入延迟的请求百分比
+	DelayPercent float64 `yaml:"delayPercent"`
+	// 直接返回的错误码
+	AbortCode int `yaml:"abortCode"`
+	// 注入错误的请求百分比
+	AbortPercent float64 `yaml:"abortPercent"`
+	Conditions   []Tag   `yaml:"conditions"`
+	tagRule      tag.Rule
+}
+
+type Tag struct {
+	ID       string `yaml:"tagId"`
+	Type     string `yaml:"tagType"`
+	Field    string `yaml:"tagField"`
+	Operator string `yaml:"tagOperator"`
+	Value    string `yaml:"tagValue"`
+}
+
+func (rule *Rule) genTagRules() {
+	var tagRule tag.Rule
+	tagRule.Expression = tag.AND
+	tagRule.ID = rule.ID
+	tagRule.Name = rule.Name
+	for _, faultTag := range rule.Conditions {
+		var t tag.Tag
+		if faultTag.Type == "S" && faultTag.Field == "source.namespace.service.name" {
+			values := strings.SplitN(faultTag.Value, "/", 2)
+			if len(values) != 2 {
+				continue
+			}
+			t.Field = meta.SourceKey(meta.Namespace)
+			t.Operator = faultTag.Operator
+			t.Type = tag.TypeSys
+			t.Value = values[0]
+			tagRule.Tags = append(tagRule.Tags, t)
+
+			t.Field = meta.SourceKey(meta.ServiceName)
+			t.Operator = faultTag.Operator
+			t.Type = tag.TypeSys
+			t.Value = values[1]
+			tagRule.Tags = append(tagRule.Tags, t)
+			continue
+		}
+		t.Field = faultTag.Field
+		// 服务端的目标服务就是自身，客户端保留destination前缀匹配被调服务
+		if rule.Side == SideServer && strings.HasPrefix(t.Field, meta.PrefixDest) {
+			t.Field = strings.TrimPrefix(t.Field, meta.PrefixDest)
+		}
+		t.Operator = faultTag.Operator
+		if faultTag.Type == "S" {
+			t.Type = tag.TypeSys
+		} else {
+			t.Type = tag.TypeUser
+		}
+		t.Value = faultTag.Value
+		tagRule.Tags = append(tagRule.Tags, t)
+	}
+	rule.tagRule = tagRule
+}
+
+// valid 未指定生效位置或没有注入任何故障的规则直接忽略
+func (rule *Rule) valid() bool {
+	if rule.Side != SideServer && rule.Side != SideClient {
+		return false
+	}
+	delay := rule.Delay > 0 && rule.DelayPercent > 0
+	abort := rule.AbortCode > 0 && rule.AbortPercent > 0
+	return delay || abort
+}
@@ -0,0 +1,51 @@
+package tsf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const faultRule = `- rules:
+  - ruleId: fault-1
+    side: SERVER
+    abortCode: 503
+    abortPercent: 100
+  - ruleId: fault-2
+    side: CLIENT
+    abortCode: 504
+    abortPercent: 100
+`
+
+func TestFaultInjectionOptIn(t *testing.T) {
+	key := "faultinjection/" + testNamespace + "/provider-fault/data"
+	fakeConsul.Put(key, []byte(faultRule))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	server := serverContext("provider-fault", "/helloworld.Greeter/SayHello")
+	client := clientContext("provider-fault", "provider", "/helloworld.Greeter/SayHello")
+
+	// 没有开启故障注入时不订阅规则
+	_, err := ServerMiddleware()(handler)(server, nil)
+	assert.Nil(t, err)
+	_, err = ClientMiddleware()(handler)(client, nil)
+	assert.Nil(t, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 0, fakeConsul.Queries(key))
+
+	serverHandler := ServerMiddleware(WithFaultInjection(nil))(handler)
+	clientHandler := ClientMiddleware(WithClientFaultInjection(nil))(handler)
+	assert.Eventually(t, func() bool {
+		_, err := serverHandler(server, nil)
+		return errors.Code(err) == 503
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, err := clientHandler(client, nil)
+		return errors.Code(err) == 504
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, fakeConsul.Queries(key) > 0)
+}
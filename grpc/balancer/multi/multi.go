@@ -17,6 +17,7 @@ import (
 
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
+	grpcmd "google.golang.org/grpc/metadata"
 )
 
 var (
@@ -109,7 +110,9 @@ func (p *Picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 		log.DefaultLog.Errorw("msg", "picker: ErrNoSubConnAvailable!", "service", svc.Name)
 		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
 	}
-	node, _ := p.b.Pick(info.Ctx, nodes)
+	nodes = tBalancer.Exclude(info.Ctx, nodes)
+	node, done := p.b.Pick(info.Ctx, nodes)
+	tBalancer.Record(info.Ctx, node)
 	span := zipkin.SpanFromContext(info.Ctx)
 	if span != nil {
 		ep, _ := zipkin.NewEndpoint(node.Service.Name, node.Addr())
@@ -119,6 +122,22 @@ func (p *Picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 
 	return balancer.PickResult{
 		SubConn: sc,
-		Done:    func(di balancer.DoneInfo) {},
+		Done: func(di balancer.DoneInfo) {
+			if done != nil {
+				done(tBalancer.DoneInfo{Err: di.Err, Trailer: trailerToMap(di.Trailer)})
+			}
+		},
 	}, nil
 }
+
+// trailerToMap converts grpc trailer metadata into the balancer trailer,
+// only the first value of each key is kept.
+func trailerToMap(md grpcmd.MD) map[string]string {
+	trailer := make(map[string]string, len(md))
+	for k, v := range md {
+		if len(v) > 0 {
+			trailer[k] = v[0]
+		}
+	}
+	return trailer
+}
@@ -0,0 +1,61 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tBalancer "github.com/tencentyun/tsf-go/balancer"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
+)
+
+type router struct{}
+
+func (router) Select(ctx context.Context, svc naming.Service, nodes []naming.Instance) []naming.Instance {
+	return nodes
+}
+
+// recorder 记录负载均衡收到的DoneInfo
+type recorder struct {
+	dones []tBalancer.DoneInfo
+}
+
+func (r *recorder) Pick(ctx context.Context, nodes []naming.Instance) (*naming.Instance, func(tBalancer.DoneInfo)) {
+	return &nodes[0], func(di tBalancer.DoneInfo) {
+		r.dones = append(r.dones, di)
+	}
+}
+
+func (r *recorder) Schema() string { return "recorder" }
+
+func TestDoneInfo(t *testing.T) {
+	r := &recorder{}
+	p := &Picker{
+		instances: []naming.Instance{{Host: "127.0.0.1", Port: 8080}},
+		subConns:  map[string]balancer.SubConn{},
+		r:         router{},
+		b:         r,
+	}
+	ctx := meta.WithSys(context.Background(),
+		meta.SysPair{Key: meta.DestKey(meta.ServiceName), Value: "provider"},
+		meta.SysPair{Key: meta.DestKey(meta.ServiceNamespace), Value: ""},
+	)
+	res, err := p.Pick(balancer.PickInfo{FullMethodName: "/helloworld.Greeter/SayHello", Ctx: ctx})
+	if !assert.Nil(t, err) {
+		return
+	}
+	callErr := errors.New("unavailable")
+	res.Done(balancer.DoneInfo{
+		Err:     callErr,
+		Trailer: metadata.Pairs(tBalancer.TrailerCPU, "800", tBalancer.TrailerInflight, "3", tBalancer.TrailerInflight, "4"),
+	})
+	if assert.Len(t, r.dones, 1) {
+		assert.Equal(t, callErr, r.dones[0].Err)
+		// 同一个key只保留第一个值
+		assert.Equal(t, map[string]string{tBalancer.TrailerCPU: "800", tBalancer.TrailerInflight: "3"}, r.dones[0].Trailer)
+	}
+}
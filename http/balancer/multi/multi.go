@@ -3,9 +3,12 @@ package multi
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"sync"
 
 	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/go-kratos/kratos/v2/transport"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
 	"github.com/go-kratos/kratos/v2/transport/http/balancer"
 	"github.com/openzipkin/zipkin-go"
 	tBalancer "github.com/tencentyun/tsf-go/balancer"
@@ -21,6 +24,9 @@ type Balancer struct {
 
 	lock  sync.RWMutex
 	nodes []naming.Instance
+
+	// requests waiting for the server load reported in response header
+	pending sync.Map
 }
 
 func New(router route.Router, b tBalancer.Balancer) *Balancer {
@@ -44,13 +50,35 @@ func (b *Balancer) Pick(ctx context.Context) (node *registry.ServiceInstance, do
 		log.DefaultLog.Errorf("picker: ErrNoSubConnAvailable after route filter!  %s", svc.Name)
 		return nil, nil, fmt.Errorf("no instances available")
 	}
-	ins, _ := b.b.Pick(ctx, filters)
+	filters = tBalancer.Exclude(ctx, filters)
+	ins, pickDone := b.b.Pick(ctx, filters)
+	tBalancer.Record(ctx, ins)
+	req := request(ctx)
+	if req != nil {
+		b.pending.Store(req, map[string]string{})
+	}
 	span := zipkin.SpanFromContext(ctx)
 	if span != nil {
 		ep, _ := zipkin.NewEndpoint(ins.Service.Name, ins.Addr())
 		span.SetRemoteEndpoint(ep)
 	}
-	return ins.ToKratosInstance(), func(context.Context, balancer.DoneInfo) {}, nil
+	return ins.ToKratosInstance(), func(ctx context.Context, di balancer.DoneInfo) {
+		trailer := di.Trailer
+		if req != nil {
+			v, _ := b.pending.Load(req)
+			// ErrorDecoder被替换时不会收集负载，这里无论如何都要删除
+			b.pending.Delete(req)
+			if load, ok := v.(map[string]string); ok && len(load) > 0 {
+				for k, v := range di.Trailer {
+					load[k] = v
+				}
+				trailer = load
+			}
+		}
+		if pickDone != nil {
+			pickDone(tBalancer.DoneInfo{Err: di.Err, Trailer: trailer})
+		}
+	}, nil
 }
 
 func (b *Balancer) Update(nodes []*registry.ServiceInstance) {
@@ -62,3 +90,35 @@ func (b *Balancer) Update(nodes []*registry.ServiceInstance) {
 	}
 	b.nodes = inss
 }
+
+// ErrorDecoder wraps the http error decoder, it collects the server load
+// reported in response header for the balancer before decoding the error.
+// If it is replaced by another http.WithErrorDecoder, the load is not collected,
+// the pending request is still released when the call is done.
+func (b *Balancer) ErrorDecoder(next khttp.DecodeErrorFunc) khttp.DecodeErrorFunc {
+	if next == nil {
+		next = khttp.DefaultErrorDecoder
+	}
+	return func(ctx context.Context, res *http.Response) error {
+		if req := request(ctx); req != nil {
+			if v, ok := b.pending.Load(req); ok {
+				load := v.(map[string]string)
+				for _, key := range []string{tBalancer.TrailerCPU, tBalancer.TrailerInflight} {
+					if value := res.Header.Get(key); value != "" {
+						load[key] = value
+					}
+				}
+			}
+		}
+		return next(ctx, res)
+	}
+}
+
+func request(ctx context.Context) *http.Request {
+	if tr, ok := transport.FromClientContext(ctx); ok {
+		if ht, ok := tr.(*khttp.Transport); ok {
+			return ht.Request()
+		}
+	}
+	return nil
+}
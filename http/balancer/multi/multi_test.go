@@ -0,0 +1,147 @@
+package multi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/registry"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/stretchr/testify/assert"
+	tBalancer "github.com/tencentyun/tsf-go/balancer"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+)
+
+type router struct{}
+
+func (router) Select(ctx context.Context, svc naming.Service, nodes []naming.Instance) []naming.Instance {
+	return nodes
+}
+
+// recorder 记录负载均衡收到的DoneInfo
+type recorder struct {
+	mu    sync.Mutex
+	dones []tBalancer.DoneInfo
+}
+
+func (r *recorder) Pick(ctx context.Context, nodes []naming.Instance) (*naming.Instance, func(tBalancer.DoneInfo)) {
+	return &nodes[0], func(di tBalancer.DoneInfo) {
+		r.mu.Lock()
+		r.dones = append(r.dones, di)
+		r.mu.Unlock()
+	}
+}
+
+func (r *recorder) Schema() string { return "recorder" }
+
+func (r *recorder) last() tBalancer.DoneInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dones[len(r.dones)-1]
+}
+
+type discovery struct {
+	ins *registry.ServiceInstance
+}
+
+func (d *discovery) GetService(ctx context.Context, name string) ([]*registry.ServiceInstance, error) {
+	return []*registry.ServiceInstance{d.ins}, nil
+}
+
+func (d *discovery) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	w := &watcher{ins: d.ins, stop: make(chan struct{})}
+	return w, nil
+}
+
+type watcher struct {
+	ins  *registry.ServiceInstance
+	once sync.Once
+	stop chan struct{}
+}
+
+func (w *watcher) Next() (res []*registry.ServiceInstance, err error) {
+	w.once.Do(func() {
+		res = []*registry.ServiceInstance{w.ins}
+	})
+	if res != nil {
+		return
+	}
+	<-w.stop
+	return nil, context.Canceled
+}
+
+func (w *watcher) Stop() error {
+	close(w.stop)
+	return nil
+}
+
+func (b *Balancer) pendings() (n int) {
+	b.pending.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	return
+}
+
+func TestDoneInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(tBalancer.TrailerCPU, "800")
+		w.Header().Set(tBalancer.TrailerInflight, "3")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	d := &discovery{ins: &registry.ServiceInstance{
+		ID:        "provider-1",
+		Name:      "provider",
+		Endpoints: []string{"http://" + srv.Listener.Addr().String()},
+	}}
+	ctx := meta.WithSys(context.Background(),
+		meta.SysPair{Key: meta.DestKey(meta.ServiceName), Value: "provider"},
+		meta.SysPair{Key: meta.DestKey(meta.ServiceNamespace), Value: ""},
+	)
+
+	tests := []struct {
+		name    string
+		decoder func(b *Balancer) khttp.DecodeErrorFunc
+		trailer map[string]string
+	}{
+		{
+			name:    "collect load",
+			decoder: func(b *Balancer) khttp.DecodeErrorFunc { return b.ErrorDecoder(nil) },
+			trailer: map[string]string{tBalancer.TrailerCPU: "800", tBalancer.TrailerInflight: "3"},
+		},
+		{
+			// ErrorDecoder被替换时不收集负载，但pending的请求仍然要释放
+			name:    "decoder replaced",
+			decoder: func(b *Balancer) khttp.DecodeErrorFunc { return khttp.DefaultErrorDecoder },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &recorder{}
+			b := New(router{}, r)
+			cli, err := khttp.NewClient(context.Background(),
+				khttp.WithEndpoint("discovery:///provider"),
+				khttp.WithDiscovery(d),
+				khttp.WithBalancer(b),
+				khttp.WithBlock(),
+				khttp.WithErrorDecoder(test.decoder(b)),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer cli.Close()
+			err = cli.Invoke(ctx, "GET", "/hello", nil, nil)
+			assert.Equal(t, 503, int(errors.Code(err)))
+
+			di := r.last()
+			assert.Equal(t, 503, int(errors.Code(di.Err)))
+			assert.Equal(t, test.trailer, di.Trailer)
+			assert.Equal(t, 0, b.pendings())
+		})
+	}
+}
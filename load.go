@@ -0,0 +1,53 @@
+package tsf
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/tencentyun/tsf-go/balancer"
+	"github.com/tencentyun/tsf-go/gin"
+	"github.com/tencentyun/tsf-go/pkg/sys/cpu"
+	"google.golang.org/grpc"
+	grpcmd "google.golang.org/grpc/metadata"
+)
+
+// inflight is the number of requests being handled by server middleware.
+var inflight int64
+
+// Inflight returns the number of requests being handled by server.
+func Inflight() int64 {
+	return atomic.LoadInt64(&inflight)
+}
+
+// WithLoadReport reports cpu usage and inflight requests of the server to clients
+// by grpc trailer or http response header, p2c balancer of the clients takes them
+// into account to move traffic off saturated instances.
+func WithLoadReport(enable bool) ServerOption {
+	return func(o *serverOpionts) {
+		o.loadReport = enable
+	}
+}
+
+func loadReportMiddleware() middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			usage := strconv.FormatUint(cpu.Usage(), 10)
+			reqs := strconv.FormatInt(Inflight(), 10)
+			if c, ok := gin.FromGinContext(ctx); ok {
+				c.Ctx.Header(balancer.TrailerCPU, usage)
+				c.Ctx.Header(balancer.TrailerInflight, reqs)
+			} else if tr, ok := transport.FromServerContext(ctx); ok {
+				if tr.Kind() == transport.KindGRPC {
+					grpc.SetTrailer(ctx, grpcmd.Pairs(balancer.TrailerCPU, usage, balancer.TrailerInflight, reqs))
+				} else if tr.ReplyHeader() != nil {
+					tr.ReplyHeader().Set(balancer.TrailerCPU, usage)
+					tr.ReplyHeader().Set(balancer.TrailerInflight, reqs)
+				}
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -64,11 +64,16 @@ func clientMetricsMiddleware() middleware.Middleware {
 
 			method, operation := ClientOperation(ctx)
 			stat := getClientStat(ctx, remoteServiceName, operation, method)
+			ctx, rejected := withBreakerRejected(ctx)
 			defer func() {
 				var code = 200
 				if err != nil {
 					code = int(errors.FromError(err).GetCode())
 				}
+				// 熔断拒绝的请求单独统计,降级结果另外记录
+				if *rejected {
+					code = monitor.StatusBreakerOpen
+				}
 				stat.Record(code)
 			}()
 
@@ -36,15 +36,32 @@ func DefaultConsul() *Consul {
 	mu.Lock()
 	defer mu.Unlock()
 	if defaultConsul == nil {
-		defaultConsul = New(&Config{Address: env.ConsulAddressList(), Token: env.Token()})
+		defaultConsul = New(&Config{
+			Address:        env.ConsulAddressList(),
+			Token:          env.Token(),
+			Scheme:         env.ConsulScheme(),
+			TLS:            http.ConsulTLSConfig(),
+			ProtectPercent: env.ConsulProtectPercent(),
+			SnapshotDir:    env.ConsulSnapshotDir(),
+		})
 	}
 	return defaultConsul
 }
 
+// CloseDefault closes the default consul if it has been created.
+func CloseDefault() {
+	mu.Lock()
+	c := defaultConsul
+	mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
 func New(conf *Config) *Consul {
 	c := &Consul{
-		queryCli:  http.NewClient(http.WithTimeout(time.Second * 120)),
-		setCli:    http.NewClient(http.WithTimeout(time.Second * 30)),
+		queryCli:  http.NewClient(append(conf.clientOptions(), http.WithTimeout(time.Second*120))...),
+		setCli:    http.NewClient(append(conf.clientOptions(), http.WithTimeout(time.Second*30))...),
 		registry:  make(map[string]*insInfo),
 		discovery: make(map[naming.Service]*svcInfo),
 		bc: &util.BackoffConfig{
@@ -55,6 +72,9 @@ func New(conf *Config) *Consul {
 		},
 		conf: conf,
 	}
+	if conf != nil {
+		c.endpoints = http.NewEndpoints(conf.Address)
+	}
 	if conf != nil && conf.Catalog {
 		go c.Catalog()
 	}
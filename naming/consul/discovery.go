@@ -3,8 +3,8 @@ package consul
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	xhttp "net/http"
+	"net/url"
 	"strconv"
 	"sync"
 	"time"
@@ -151,17 +151,75 @@ type ServiceDefinition struct {
 
 type Config struct {
 	Address []string
-	Token   string
+	// 通过X-Consul-Token header鉴权
+	Token string
+	// http或https，默认http；配置了TLS时默认https
+	Scheme string
+	TLS    *http.TLSConfig
 	// additional message:tsf namespaceid and tencent appid if exsist
 	AppID       string
 	NamespaceID string
 
 	Catalog bool
+
+	// 下线实例比例超过ProtectPercent时保留上一次的实例列表
+	// 0表示只在实例全部消失时保护
+	ProtectPercent int
+	// 被保护的实例列表连续ProtectRefreshes次刷新没有变化时认为是真实的缩容，接受新的实例列表
+	// 默认值 3
+	ProtectRefreshes int
+	// 保护持续超过ProtectTimeout时接受新的实例列表
+	// 默认值 3m
+	ProtectTimeout time.Duration
+	// 服务实例列表的本地快照目录，consul不可用时启动会加载快照
+	// 为空表示不开启快照
+	SnapshotDir string
+}
+
+func (conf *Config) scheme() string {
+	if conf.Scheme != "" {
+		return conf.Scheme
+	}
+	if conf.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// query returns the tsf namespace and appid query of agent apis.
+func (conf *Config) query() string {
+	q := make(url.Values)
+	if conf.NamespaceID != "" {
+		q.Set("nid", conf.NamespaceID)
+	}
+	if conf.AppID != "" {
+		q.Set("uid", conf.AppID)
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+// clientOptions returns the http client options of tls and acl token.
+func (conf *Config) clientOptions() (opts []http.Option) {
+	if conf == nil {
+		return
+	}
+	if conf.Token != "" {
+		opts = append(opts, http.WithHeader("X-Consul-Token", conf.Token))
+	}
+	if conf.TLS != nil {
+		// 证书加载失败时请求直接返回错误，不会降级发送token
+		opts = append(opts, http.WithTLS(conf.TLS))
+	}
+	return
 }
 
 type Consul struct {
 	queryCli  *http.Client
 	setCli    *http.Client
+	endpoints *http.Endpoints
 	bc        *util.BackoffConfig
 	registry  map[string]*insInfo
 	discovery map[naming.Service]*svcInfo
@@ -170,23 +228,36 @@ type Consul struct {
 	conf *Config
 }
 
-func (c *Consul) addr() string {
-	if len(c.conf.Address) == 0 {
-		return ""
-	} else if len(c.conf.Address) == 1 {
-		return c.conf.Address[0]
+func (c *Consul) protectPercent() int {
+	if c.conf == nil {
+		return 0
 	}
+	return c.conf.ProtectPercent
+}
 
-	return c.conf.Address[rand.Intn(len(c.conf.Address))]
+// do sends the request to the healthiest consul address,
+// the read request is retried on other addresses if failed.
+func (c *Consul) do(typ http.CallType, method, path string, reqBody, respBody interface{}) (header xhttp.Header, url string, err error) {
+	cli := c.queryCli
+	if typ == http.CallWrite {
+		cli = c.setCli
+	}
+	err = c.endpoints.Do(typ, func(addr string) (err error) {
+		url = c.conf.scheme() + "://" + addr + path
+		header, err = cli.Do(method, url, reqBody, respBody)
+		return
+	})
+	return
 }
 
 func (c *Consul) catalog(index int64) (services map[string]interface{}, consulIndex int64, err error) {
-	url := fmt.Sprintf("http://%s/v1/catalog/services?token=%s&wait=55s&index=%d", c.addr(), c.conf.Token, index)
+	var url string
+	path := fmt.Sprintf("/v1/catalog/services?wait=55s&index=%d", index)
 	if c.conf.NamespaceID != "" {
-		url += "&nid=" + c.conf.NamespaceID
+		path += "&nid=" + c.conf.NamespaceID
 	}
 	if c.conf.AppID != "" {
-		url += "&uid=" + c.conf.AppID
+		path += "&uid=" + c.conf.AppID
 	}
 	defer func() {
 		if err != nil {
@@ -195,7 +266,7 @@ func (c *Consul) catalog(index int64) (services map[string]interface{}, consulIn
 	}()
 	var header xhttp.Header
 	services = map[string]interface{}{}
-	header, err = c.queryCli.Get(url, &services)
+	header, url, err = c.do(http.CallBlockingRead, "GET", path, nil, &services)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			err = nil
@@ -218,7 +289,8 @@ func (c *Consul) catalog(index int64) (services map[string]interface{}, consulIn
 }
 
 func (c *Consul) healthService(svc naming.Service, index int64) (nodes []CheckServiceNode, consulIndex int64, err error) {
-	url := fmt.Sprintf("http://%s/v1/health/service/%s?token=%s&passing&wait=55s&index=%d", c.addr(), svc.Name, c.conf.Token, index)
+	var url string
+	path := fmt.Sprintf("/v1/health/service/%s?passing&wait=55s&index=%d", svc.Name, index)
 	/*if svc.NameSpace == "global" {
 		url += "&nsType=GLOBAL"
 	} else if svc.NameSpace == "all" {
@@ -229,15 +301,15 @@ func (c *Consul) healthService(svc naming.Service, index int64) (nodes []CheckSe
 	}*/
 	if svc.Namespace != "" && svc.Namespace != env.NamespaceID() {
 		if svc.Namespace == naming.NsGlobal {
-			url += "&nsType=GLOBAL"
+			path += "&nsType=GLOBAL"
 		} else {
-			url += "&nid=" + svc.Namespace
+			path += "&nid=" + svc.Namespace
 		}
 	} else if c.conf.NamespaceID != "" {
-		url += "&nid=" + c.conf.NamespaceID
+		path += "&nid=" + c.conf.NamespaceID
 	}
 	if c.conf.AppID != "" {
-		url += "&uid=" + c.conf.AppID
+		path += "&uid=" + c.conf.AppID
 	}
 	defer func() {
 		if err != nil {
@@ -245,7 +317,7 @@ func (c *Consul) healthService(svc naming.Service, index int64) (nodes []CheckSe
 		}
 	}()
 	var header xhttp.Header
-	header, err = c.queryCli.Get(url, &nodes)
+	header, url, err = c.do(http.CallBlockingRead, "GET", path, nil, &nodes)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			err = nil
@@ -343,11 +415,22 @@ func (s *svcInfo) subscribe(ctx context.Context, svc naming.Service) {
 		lastNodes []CheckServiceNode
 		lastIndex int64
 		err       error
+		// 从快照加载的实例只做零实例保护
+		fromSnapshot bool
+		protector    = newProtector(s.consul.conf)
 	)
 
 	lastNodes, lastIndex, err = s.consul.healthService(svc, lastIndex)
 	if err == nil && len(lastNodes) > 0 {
 		s.broadcast(lastNodes)
+		s.consul.saveSnapshot(svc, lastNodes)
+	} else if err != nil {
+		lastNodes, _ = s.consul.loadSnapshot(svc)
+		if len(lastNodes) > 0 {
+			log.DefaultLog.Warnw("msg", "[naming] consul unreachable, load instances from snapshot!", "name", svc.Name, "count", len(lastNodes))
+			fromSnapshot = true
+			s.broadcast(lastNodes)
+		}
 	}
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
@@ -357,17 +440,26 @@ func (s *svcInfo) subscribe(ctx context.Context, svc naming.Service) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			nodes, index, err := s.
-				consul.healthService(svc, lastIndex)
+			nodes, index, err := s.consul.healthService(svc, lastIndex)
 			if err != nil {
 				time.Sleep(s.consul.bc.Backoff(retries))
 				retries++
 				continue
 			}
-			if len(nodes) != 0 {
-				if index != lastIndex || !compareNodes(lastNodes, nodes) {
+			if index != lastIndex || !compareNodes(lastNodes, nodes) {
+				percent := s.consul.protectPercent()
+				if fromSnapshot {
+					percent = 0
+				}
+				if protector.reject(lastNodes, nodes, percent, time.Now()) {
+					if len(lastNodes) > 0 {
+						log.DefaultLog.Warnw("msg", "[naming] too many instances dropped, keep the last instances!", "name", svc.Name, "last", len(lastNodes), "current", len(nodes))
+					}
+				} else {
 					lastNodes = nodes
+					fromSnapshot = false
 					s.broadcast(nodes)
+					s.consul.saveSnapshot(svc, nodes)
 				}
 			}
 			retries = 0
@@ -424,6 +516,23 @@ func (w *Watcher) Next() (nodes []*registry.ServiceInstance, err error) {
 	return
 }
 
+// Close stops all the heartbeats and service subscriptions.
+func (c *Consul) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for id, v := range c.registry {
+		v.cancel()
+		delete(c.registry, id)
+	}
+	for svc, v := range c.discovery {
+		for w := range v.watcher {
+			w.cancel()
+		}
+		v.cancel()
+		delete(c.discovery, svc)
+	}
+}
+
 func (w *Watcher) Stop() error {
 	select {
 	case <-w.ctx.Done():
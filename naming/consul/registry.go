@@ -9,6 +9,7 @@ import (
 	"github.com/go-kratos/kratos/v2/registry"
 	"github.com/tencentyun/tsf-go/log"
 	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/http"
 )
 
 func (c *Consul) Register(ctx context.Context, ki *registry.ServiceInstance) (err error) {
@@ -72,7 +73,7 @@ func (c *Consul) registerIns(ins *naming.Instance) (err error) {
 
 func (c *Consul) Deregister(ctx context.Context, ki *registry.ServiceInstance) (err error) {
 	for _, ins := range naming.FromKratosInstance(ki) {
-		err := c.deregister(ins)
+		err := c.deregisterIns(ins)
 		if err != nil {
 			return err
 		}
@@ -82,14 +83,15 @@ func (c *Consul) Deregister(ctx context.Context, ki *registry.ServiceInstance) (
 
 func (c *Consul) deregisterIns(ins *naming.Instance) (err error) {
 	log.DefaultLog.Infow("msg", "deregister service!", "svc", ins.Service.Name)
-	c.lock.RLock()
+	// 先停止心跳，避免注销后又被心跳重新注册
+	c.lock.Lock()
 	v, ok := c.registry[ins.ID]
-	c.lock.RUnlock()
+	delete(c.registry, ins.ID)
+	c.lock.Unlock()
 	if ok && v != nil {
 		v.cancel()
 	}
-	c.deregister(ins)
-	return
+	return c.deregister(ins)
 }
 
 func (c *Consul) register(ins *naming.Instance) (err error) {
@@ -108,14 +110,7 @@ func (c *Consul) register(ins *naming.Instance) (err error) {
 	/*for k, v := range ins.Metadata {
 		sd.Tags = append(sd.Tags, k+"="+v)
 	}*/
-	url := fmt.Sprintf("http://%s/v1/agent/service/register?token=%s", c.addr(), c.conf.Token)
-	if c.conf.NamespaceID != "" {
-		url += "&nid=" + c.conf.NamespaceID
-	}
-	if c.conf.AppID != "" {
-		url += "&uid=" + c.conf.AppID
-	}
-	err = c.setCli.Put(url, sd, nil)
+	_, url, err := c.do(http.CallWrite, "PUT", "/v1/agent/service/register"+c.conf.query(), sd, nil)
 	if err != nil {
 		log.DefaultLog.Errorw("msg", "[naming] register instance to consul failed!", "instance", sd, "url", url, "err", err)
 	} else {
@@ -125,14 +120,7 @@ func (c *Consul) register(ins *naming.Instance) (err error) {
 }
 
 func (c *Consul) heartBeat(ins *naming.Instance) (err error) {
-	url := fmt.Sprintf("http://%s/v1/agent/check/pass/%s?token=%s", c.addr(), checkID(ins), c.conf.Token)
-	if c.conf.NamespaceID != "" {
-		url += "&nid=" + c.conf.NamespaceID
-	}
-	if c.conf.AppID != "" {
-		url += "&uid=" + c.conf.AppID
-	}
-	err = c.setCli.Put(url, nil, nil)
+	_, url, err := c.do(http.CallWrite, "PUT", fmt.Sprintf("/v1/agent/check/pass/%s", checkID(ins))+c.conf.query(), nil, nil)
 	if err != nil {
 		log.DefaultLog.Errorw("msg", "[naming] send heartbeat to consul failed!", "id", ins.ID, "url", url, "err", err)
 	}
@@ -140,14 +128,7 @@ func (c *Consul) heartBeat(ins *naming.Instance) (err error) {
 }
 
 func (c *Consul) deregister(ins *naming.Instance) (err error) {
-	url := fmt.Sprintf("http://%s/v1/agent/service/deregister/%s?token=%s", c.addr(), ins.ID, c.conf.Token)
-	if c.conf.NamespaceID != "" {
-		url += "&nid=" + c.conf.NamespaceID
-	}
-	if c.conf.AppID != "" {
-		url += "&uid=" + c.conf.AppID
-	}
-	err = c.setCli.Put(url, nil, nil)
+	_, url, err := c.do(http.CallWrite, "PUT", fmt.Sprintf("/v1/agent/service/deregister/%s", ins.ID)+c.conf.query(), nil, nil)
 	if err != nil {
 		log.DefaultLog.Errorw("msg", "[naming] deregister ins to consul failed!", "id", ins.ID, "url", url, "err", err)
 	}
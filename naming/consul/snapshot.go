@@ -0,0 +1,147 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/naming"
+)
+
+// protect 判断本次刷新是否应该被拒绝，保留上一次的实例列表
+// 实例全部消失时总是拒绝；percent大于0时，下线实例比例超过percent也拒绝
+func protect(last, nodes []CheckServiceNode, percent int) bool {
+	if len(nodes) == 0 {
+		return true
+	}
+	if percent <= 0 || len(last) == 0 {
+		return false
+	}
+	var dropped int
+	for _, old := range last {
+		found := false
+		for i := range nodes {
+			if old.compare(&nodes[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dropped++
+		}
+	}
+	return dropped*100 > len(last)*percent
+}
+
+const (
+	// 默认同一个被拒绝的实例列表连续刷新3次后接受
+	defaultProtectRefreshes = 3
+	// 默认保护超过3分钟后接受新的实例列表
+	defaultProtectTimeout = 3 * time.Minute
+)
+
+// protector 对实例下线做有限期的保护：被拒绝的实例列表连续refreshes次刷新没有变化，
+// 或者保护持续超过timeout，认为是真实的缩容，接受新的实例列表
+// 实例全部消失时总是保护
+type protector struct {
+	refreshes int
+	timeout   time.Duration
+
+	pending []CheckServiceNode
+	count   int
+	since   time.Time
+}
+
+func newProtector(conf *Config) *protector {
+	p := &protector{refreshes: defaultProtectRefreshes, timeout: defaultProtectTimeout}
+	if conf != nil && conf.ProtectRefreshes > 0 {
+		p.refreshes = conf.ProtectRefreshes
+	}
+	if conf != nil && conf.ProtectTimeout > 0 {
+		p.timeout = conf.ProtectTimeout
+	}
+	return p
+}
+
+// reject 判断本次刷新是否应该被拒绝
+func (p *protector) reject(last, nodes []CheckServiceNode, percent int, now time.Time) bool {
+	if !protect(last, nodes, percent) {
+		p.reset()
+		return false
+	}
+	if len(nodes) == 0 {
+		return true
+	}
+	if p.since.IsZero() {
+		p.since = now
+	}
+	if p.pending != nil && compareNodes(p.pending, nodes) {
+		p.count++
+	} else {
+		p.pending, p.count = nodes, 1
+	}
+	if p.count >= p.refreshes || now.Sub(p.since) >= p.timeout {
+		p.reset()
+		return false
+	}
+	return true
+}
+
+func (p *protector) reset() {
+	p.pending, p.count, p.since = nil, 0, time.Time{}
+}
+
+func (c *Consul) snapshotFile(svc naming.Service) string {
+	namespace := svc.Namespace
+	if namespace == "" {
+		namespace = c.conf.NamespaceID
+	}
+	return filepath.Join(c.conf.SnapshotDir, url.PathEscape(fmt.Sprintf("%s#%s", namespace, svc.Name))+".json")
+}
+
+// saveSnapshot 将服务的实例列表写入本地快照目录
+func (c *Consul) saveSnapshot(svc naming.Service, nodes []CheckServiceNode) (err error) {
+	if c.conf == nil || c.conf.SnapshotDir == "" {
+		return
+	}
+	defer func() {
+		if err != nil {
+			log.DefaultLog.Errorw("msg", "[naming] save discovery snapshot failed!", "name", svc.Name, "err", err)
+		}
+	}()
+	content, err := json.Marshal(nodes)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(c.conf.SnapshotDir, 0755); err != nil {
+		return
+	}
+	file := c.snapshotFile(svc)
+	// 先写临时文件再rename，避免进程退出时留下不完整的快照
+	tmp := file + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return
+	}
+	return os.Rename(tmp, file)
+}
+
+// loadSnapshot 读取本地快照中的实例列表，用于consul不可用时冷启动
+func (c *Consul) loadSnapshot(svc naming.Service) (nodes []CheckServiceNode, err error) {
+	if c.conf == nil || c.conf.SnapshotDir == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(c.snapshotFile(svc))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	err = json.Unmarshal(content, &nodes)
+	return
+}
@@ -0,0 +1,126 @@
+package consul
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/tsftest"
+)
+
+func newNodes(ports ...int) (nodes []CheckServiceNode) {
+	for _, port := range ports {
+		nodes = append(nodes, CheckServiceNode{
+			Node:    &Node{Address: "127.0.0.1"},
+			Service: &NodeService{ID: "provider-" + strconv.Itoa(port), Service: "provider", Address: "127.0.0.1", Port: port},
+		})
+	}
+	return
+}
+
+func TestProtect(t *testing.T) {
+	last := newNodes(8080, 8081, 8082, 8083)
+	assert.True(t, protect(last, nil, 0))
+	assert.False(t, protect(nil, newNodes(8080), 50))
+	assert.False(t, protect(last, newNodes(8080), 0))
+	// 下线2/4，未超过50%
+	assert.False(t, protect(last, newNodes(8080, 8081), 50))
+	// 下线3/4
+	assert.True(t, protect(last, newNodes(8080), 50))
+	// 新增实例不算下线
+	assert.False(t, protect(last, newNodes(8080, 8081, 8084, 8085), 50))
+}
+
+func TestProtector(t *testing.T) {
+	p := newProtector(&Config{ProtectRefreshes: 3, ProtectTimeout: time.Minute})
+	last := newNodes(8080, 8081, 8082, 8083)
+	now := time.Now()
+	// 实例全部消失时总是保护
+	for i := 0; i < 10; i++ {
+		assert.True(t, p.reject(last, nil, 50, now.Add(time.Hour)))
+	}
+	// 持续的缩容在连续3次刷新后被接受
+	assert.True(t, p.reject(last, newNodes(8080), 50, now))
+	assert.True(t, p.reject(last, newNodes(8080), 50, now))
+	assert.False(t, p.reject(last, newNodes(8080), 50, now))
+
+	// 实例列表变化时重新计数，但保护时间不超过ProtectTimeout
+	assert.True(t, p.reject(last, newNodes(8080), 50, now))
+	assert.True(t, p.reject(last, newNodes(8081), 50, now.Add(30*time.Second)))
+	assert.True(t, p.reject(last, newNodes(8080), 50, now.Add(40*time.Second)))
+	assert.False(t, p.reject(last, newNodes(8081), 50, now.Add(time.Minute)))
+
+	// 未触发保护时重置
+	assert.True(t, p.reject(last, newNodes(8080), 50, now))
+	assert.False(t, p.reject(last, newNodes(8080, 8081), 50, now))
+	assert.True(t, p.reject(last, newNodes(8080), 50, now))
+	assert.True(t, p.reject(last, newNodes(8080), 50, now))
+}
+
+func TestProtectScaleDown(t *testing.T) {
+	fake := tsftest.NewConsul()
+	defer fake.Close()
+	for _, port := range []int{8080, 8081, 8082, 8083} {
+		fake.Register(&naming.Instance{
+			ID:      "provider-" + strconv.Itoa(port),
+			Service: &naming.Service{Name: "provider"},
+			Host:    "127.0.0.1",
+			Port:    port,
+		})
+	}
+	c := New(&Config{Address: []string{fake.Addr()}, ProtectPercent: 50, ProtectRefreshes: 2})
+	defer c.Close()
+	w, err := c.Watch(context.Background(), "provider")
+	assert.Nil(t, err)
+	defer w.Stop()
+	inss, err := w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(inss))
+
+	// 下线3/4的实例，第一次刷新被保护
+	for _, port := range []int{8081, 8082, 8083} {
+		fake.Deregister("provider-" + strconv.Itoa(port))
+	}
+	time.Sleep(1500 * time.Millisecond)
+	inss, err = c.GetService(context.Background(), "provider")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(inss))
+
+	// consul index变化触发下一次刷新，实例列表仍然相同，认为是真实的缩容
+	fake.Put("touch", []byte("1"))
+	inss, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(inss))
+	assert.Equal(t, "provider-8080", inss[0].ID)
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	svc := naming.Service{Name: "provider"}
+	c := New(&Config{Address: []string{"127.0.0.1:1"}, SnapshotDir: dir})
+	defer c.Close()
+	nodes, err := c.loadSnapshot(svc)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(nodes))
+
+	assert.Nil(t, c.saveSnapshot(svc, newNodes(8080, 8081)))
+	nodes, err = c.loadSnapshot(svc)
+	assert.Nil(t, err)
+	assert.True(t, compareNodes(newNodes(8080, 8081), nodes))
+
+	// consul不可用时从快照加载
+	w, err := c.Watch(context.Background(), "provider")
+	assert.Nil(t, err)
+	defer w.Stop()
+	inss, err := w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(inss))
+}
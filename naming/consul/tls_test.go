@@ -0,0 +1,55 @@
+package consul
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/naming"
+	xhttp "github.com/tencentyun/tsf-go/pkg/http"
+)
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "token" || r.URL.Query().Get("token") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "1")
+		if r.URL.Path == "/v1/health/service/provider" {
+			json.NewEncoder(w).Encode(newNodes(8080))
+		}
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	assert.Nil(t, err)
+
+	c := New(&Config{
+		Address:     []string{strings.TrimPrefix(srv.URL, "https://")},
+		Token:       "token",
+		NamespaceID: "ns",
+		TLS:         &xhttp.TLSConfig{CAFile: caFile},
+	})
+	defer c.Close()
+	nodes, index, err := c.healthService(naming.Service{Name: "provider"}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), index)
+	assert.Equal(t, 1, len(nodes))
+	assert.Nil(t, c.heartBeat(&naming.Instance{ID: "provider-8080"}))
+
+	c = New(&Config{Address: []string{strings.TrimPrefix(srv.URL, "https://")}, TLS: &xhttp.TLSConfig{CAFile: caFile}})
+	defer c.Close()
+	_, _, err = c.healthService(naming.Service{Name: "provider"}, 0)
+	assert.NotNil(t, err)
+}
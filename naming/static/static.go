@@ -0,0 +1,298 @@
+package static
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/naming"
+
+	"gopkg.in/yaml.v3"
+)
+
+var _ registry.Discovery = &Static{}
+var _ registry.Registrar = &Static{}
+
+// Instance is the service instance in yaml file.
+type Instance struct {
+	Service   string `yaml:"service"`
+	Namespace string `yaml:"namespace"`
+	ID        string `yaml:"id"`
+	Host      string `yaml:"host"`
+	Port      int    `yaml:"port"`
+	// grpc或http，默认http
+	Protocol string   `yaml:"protocol"`
+	Region   string   `yaml:"region"`
+	Status   int64    `yaml:"status"`
+	Tags     []string `yaml:"tags"`
+	// TSF元信息，比如TSF_GROUP_ID、TSF_PROG_VERSION、TSF_ZONE
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+// Config is the content of yaml file.
+type Config struct {
+	Instances []Instance `yaml:"instances"`
+}
+
+// ToInstance converts to naming instance.
+func (i Instance) ToInstance() *naming.Instance {
+	metadata := make(map[string]string, len(i.Metadata)+2)
+	for k, v := range i.Metadata {
+		metadata[k] = v
+	}
+	if i.Protocol != "" {
+		metadata["protocol"] = i.Protocol
+	}
+	if i.Namespace != "" {
+		metadata[naming.NamespaceID] = i.Namespace
+	}
+	if i.Region != "" {
+		metadata[naming.Region] = i.Region
+	}
+	ins := &naming.Instance{
+		Service:  &naming.Service{Namespace: metadata[naming.NamespaceID], Name: i.Service},
+		ID:       i.ID,
+		Region:   i.Region,
+		Host:     i.Host,
+		Port:     i.Port,
+		Metadata: metadata,
+		Status:   i.Status,
+		Tags:     i.Tags,
+	}
+	if ins.ID == "" {
+		ins.ID = fmt.Sprintf("%s-%s", i.Service, ins.Addr())
+	}
+	return ins
+}
+
+// Static is a registry which serves the service instances from code or yaml file,
+// it can be used to run tsf-go locally without consul.
+type Static struct {
+	lock sync.RWMutex
+	// 代码或文件中配置的实例
+	static []*naming.Instance
+	// 通过Register注册的实例
+	registered map[string]*naming.Instance
+	services   map[string][]*registry.ServiceInstance
+	watcher    map[string]map[*Watcher]struct{}
+
+	file    string
+	fsWatch *fsnotify.Watcher
+}
+
+// New new a static registry of instances.
+func New(inss ...*naming.Instance) *Static {
+	s := &Static{
+		registered: make(map[string]*naming.Instance),
+		services:   make(map[string][]*registry.ServiceInstance),
+		watcher:    make(map[string]map[*Watcher]struct{}),
+	}
+	s.Update(inss)
+	return s
+}
+
+// NewFile new a static registry of yaml file, the instances are reloaded on file change.
+func NewFile(file string) (*Static, error) {
+	s := New()
+	s.file = file
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	fsWatch, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// 监听所在目录，编辑器保存文件时可能会先删除再新建
+	if err = fsWatch.Add(filepath.Dir(file)); err != nil {
+		fsWatch.Close()
+		return nil, err
+	}
+	s.fsWatch = fsWatch
+	go s.run()
+	return s, nil
+}
+
+func (s *Static) load() error {
+	content, err := ioutil.ReadFile(s.file)
+	if err != nil {
+		return err
+	}
+	// 文件被清空后写入时会先收到空文件的事件，忽略避免实例被清空
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil
+	}
+	var conf Config
+	if err = yaml.Unmarshal(content, &conf); err != nil {
+		return err
+	}
+	var inss []*naming.Instance
+	for _, i := range conf.Instances {
+		inss = append(inss, i.ToInstance())
+	}
+	s.Update(inss)
+	return nil
+}
+
+func (s *Static) run() {
+	for {
+		select {
+		case event, ok := <-s.fsWatch.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(s.file) || event.Op == fsnotify.Chmod || event.Op == fsnotify.Remove {
+				continue
+			}
+			if err := s.load(); err != nil {
+				log.DefaultLog.Errorw("msg", "[naming] reload static instances failed!", "file", s.file, "err", err)
+			}
+		case err, ok := <-s.fsWatch.Errors:
+			if !ok {
+				return
+			}
+			log.DefaultLog.Errorw("msg", "[naming] watch static instances failed!", "file", s.file, "err", err)
+		}
+	}
+}
+
+// Update replaces all the instances configured by code or file.
+func (s *Static) Update(inss []*naming.Instance) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.static = inss
+	s.refresh()
+}
+
+// refresh rebuilds the instances of services and notifies the watchers of changed services.
+func (s *Static) refresh() {
+	services := make(map[string][]*registry.ServiceInstance)
+	for _, ins := range s.static {
+		services[ins.Service.Name] = append(services[ins.Service.Name], ins.ToKratosInstance())
+	}
+	for _, ins := range s.registered {
+		services[ins.Service.Name] = append(services[ins.Service.Name], ins.ToKratosInstance())
+	}
+	for _, inss := range services {
+		sort.Slice(inss, func(i, j int) bool {
+			return inss[i].ID < inss[j].ID
+		})
+	}
+	old := s.services
+	s.services = services
+	for name, watchers := range s.watcher {
+		if equal(old[name], services[name]) {
+			continue
+		}
+		for w := range watchers {
+			select {
+			case w.event <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func equal(old, new []*registry.ServiceInstance) bool {
+	if len(old) != len(new) {
+		return false
+	}
+	for i := range old {
+		if old[i].ID != new[i].ID || old[i].Endpoints[0] != new[i].Endpoints[0] || fmt.Sprint(old[i].Metadata) != fmt.Sprint(new[i].Metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Static) Register(ctx context.Context, ki *registry.ServiceInstance) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, ins := range naming.FromKratosInstance(ki) {
+		s.registered[ins.ID] = ins
+	}
+	s.refresh()
+	return nil
+}
+
+func (s *Static) Deregister(ctx context.Context, ki *registry.ServiceInstance) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, ins := range naming.FromKratosInstance(ki) {
+		delete(s.registered, ins.ID)
+	}
+	s.refresh()
+	return nil
+}
+
+func (s *Static) GetService(ctx context.Context, service string) ([]*registry.ServiceInstance, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.services[service], nil
+}
+
+func (s *Static) Watch(ctx context.Context, service string) (registry.Watcher, error) {
+	w := &Watcher{
+		static:  s,
+		service: service,
+		event:   make(chan struct{}, 1),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.services[service]) > 0 {
+		w.event <- struct{}{}
+	}
+	if s.watcher[service] == nil {
+		s.watcher[service] = make(map[*Watcher]struct{})
+	}
+	s.watcher[service][w] = struct{}{}
+	return w, nil
+}
+
+// Close stops watching the yaml file.
+func (s *Static) Close() error {
+	if s.fsWatch != nil {
+		return s.fsWatch.Close()
+	}
+	return nil
+}
+
+type Watcher struct {
+	static  *Static
+	service string
+	event   chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func (w *Watcher) Next() (nodes []*registry.ServiceInstance, err error) {
+	select {
+	case <-w.ctx.Done():
+		err = errors.ClientClosed(errors.UnknownReason, "")
+		return
+	case <-w.event:
+		nodes, err = w.static.GetService(w.ctx, w.service)
+	}
+	return
+}
+
+func (w *Watcher) Stop() error {
+	select {
+	case <-w.ctx.Done():
+		return nil
+	default:
+	}
+	w.cancel()
+	w.static.lock.Lock()
+	defer w.static.lock.Unlock()
+	delete(w.static.watcher[w.service], w)
+	return nil
+}
@@ -0,0 +1,103 @@
+package static
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/naming"
+)
+
+const instances = `instances:
+  - service: provider
+    host: 127.0.0.1
+    port: 8080
+    protocol: grpc
+    metadata:
+      TSF_GROUP_ID: group-a
+      TSF_PROG_VERSION: v1
+      TSF_ZONE: zone-1
+`
+
+func next(t *testing.T, w registry.Watcher) []*registry.ServiceInstance {
+	res := make(chan []*registry.ServiceInstance, 1)
+	go func() {
+		nodes, _ := w.Next()
+		res <- nodes
+	}()
+	select {
+	case nodes := <-res:
+		return nodes
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch timeout")
+	}
+	return nil
+}
+
+func TestStatic(t *testing.T) {
+	s := New(&naming.Instance{
+		Service:  naming.NewService("ns", "provider"),
+		ID:       "provider-1",
+		Host:     "127.0.0.1",
+		Port:     8080,
+		Metadata: map[string]string{"protocol": "grpc", naming.GroupID: "group-a"},
+	})
+	w, err := s.Watch(context.Background(), "provider")
+	assert.Nil(t, err)
+	defer w.Stop()
+	nodes := next(t, w)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "grpc://127.0.0.1:8080", nodes[0].Endpoints[0])
+
+	ki := naming.Instance{Service: naming.NewService("ns", "provider"), ID: "provider-2", Host: "127.0.0.1", Port: 8081}
+	assert.Nil(t, s.Register(context.Background(), ki.ToKratosInstance()))
+	assert.Equal(t, 2, len(next(t, w)))
+	// 更新静态实例不影响注册的实例
+	s.Update(nil)
+	nodes = next(t, w)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "provider-2", nodes[0].ID)
+	assert.Nil(t, s.Deregister(context.Background(), ki.ToKratosInstance()))
+	assert.Equal(t, 0, len(next(t, w)))
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "instances.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(instances), 0644))
+
+	s, err := NewFile(file)
+	assert.Nil(t, err)
+	defer s.Close()
+	nodes, err := s.GetService(context.Background(), "provider")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "v1", nodes[0].Version)
+	assert.Equal(t, "zone-1", nodes[0].Metadata["TSF_ZONE"])
+	ins := naming.FromKratosInstance(nodes[0])[0]
+	assert.Equal(t, "group-a", ins.Metadata[naming.GroupID])
+	assert.Equal(t, "grpc", ins.Metadata["protocol"])
+
+	w, err := s.Watch(context.Background(), "provider")
+	assert.Nil(t, err)
+	defer w.Stop()
+	assert.Equal(t, 1, len(next(t, w)))
+	assert.Nil(t, ioutil.WriteFile(file, []byte(instances+`  - service: provider
+    host: 127.0.0.1
+    port: 8081
+`), 0644))
+	// 写文件可能分多次推送
+	for i := 0; i < 3; i++ {
+		if nodes = next(t, w); len(nodes) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 2, len(nodes))
+}
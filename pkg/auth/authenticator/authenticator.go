@@ -64,6 +64,12 @@ func (a *Authenticator) Verify(ctx context.Context, method string) error {
 	return nil
 }
 
+// Close stops watching the authority rules.
+func (a *Authenticator) Close() {
+	a.cancel()
+	a.watcher.Close()
+}
+
 func (a *Authenticator) refreshRule() {
 	for {
 		specs, err := a.watcher.Watch(a.ctx)
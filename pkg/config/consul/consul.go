@@ -33,17 +33,24 @@ const separator string = "-"
 
 type Config struct {
 	Address string
-	Token   string
+	// 其他consul地址，与Address一起按健康度故障切换
+	Addresses []string
+	// 通过X-Consul-Token header鉴权
+	Token string
+	// http或https，默认http；配置了TLS时默认https
+	Scheme string
+	TLS    *http.TLSConfig
 	// additional message: tsf namespaceid and tencent appid if exsist
 	AppID       string
 	NamespaceID string
 }
 
 type Consul struct {
-	queryCli *http.Client
-	bc       *util.BackoffConfig
-	lock     sync.RWMutex
-	conf     *Config
+	queryCli  *http.Client
+	endpoints *http.Endpoints
+	bc        *util.BackoffConfig
+	lock      sync.RWMutex
+	conf      *Config
 
 	topic map[string]*Topic
 }
@@ -52,17 +59,60 @@ func DefaultConsul() *Consul {
 	mu.Lock()
 	defer mu.Unlock()
 	if defaultConsul == nil {
+		addrs := env.ConsulAddressList()
 		defaultConsul = New(&Config{
-			Address: fmt.Sprintf("%s:%d", env.ConsulHost(), env.ConsulPort()),
-			Token:   env.Token(),
+			Address:   addrs[0],
+			Addresses: addrs[1:],
+			Token:     env.Token(),
+			Scheme:    env.ConsulScheme(),
+			TLS:       http.ConsulTLSConfig(),
 		})
 	}
 	return defaultConsul
 }
 
+// addresses returns Address and Addresses without duplicates.
+func (conf *Config) addresses() []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, addr := range append([]string{conf.Address}, conf.Addresses...) {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (conf *Config) scheme() string {
+	if conf.Scheme != "" {
+		return conf.Scheme
+	}
+	if conf.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// clientOptions returns the http client options of tls and acl token.
+func (conf *Config) clientOptions() (opts []http.Option) {
+	if conf == nil {
+		return
+	}
+	if conf.Token != "" {
+		opts = append(opts, http.WithHeader("X-Consul-Token", conf.Token))
+	}
+	if conf.TLS != nil {
+		// 证书加载失败时请求直接返回错误，不会降级发送token
+		opts = append(opts, http.WithTLS(conf.TLS))
+	}
+	return
+}
+
 func New(conf *Config) *Consul {
 	return &Consul{
-		queryCli: http.NewClient(http.WithTimeout(time.Second * 90)),
+		queryCli:  http.NewClient(append(conf.clientOptions(), http.WithTimeout(time.Second*90))...),
+		endpoints: http.NewEndpoints(conf.addresses()),
 		bc: &util.BackoffConfig{
 			MaxDelay:  25 * time.Second,
 			BaseDelay: 500 * time.Millisecond,
@@ -95,19 +145,24 @@ func (c *Consul) Subscribe(path string) config.Watcher {
 	}
 	w.topic = topic
 	topic.watcher[w] = struct{}{}
+	// topic已经拉取过配置时，新的watcher也要收到当前的配置
+	if topic.spec.Load() != nil {
+		w.event <- struct{}{}
+	}
 	return w
 }
 
 func (c *Consul) fetch(path string, index int64) (res []config.Spec, consulIndex int64, err error) {
-	url := fmt.Sprintf("http://%s/v1/kv/%s?token=%s&wait=55s&nsType=DEF_AND_GLOBAL&index=%d", c.conf.Address, path, c.conf.Token, index)
+	var url string
+	query := fmt.Sprintf("/v1/kv/%s?wait=55s&nsType=DEF_AND_GLOBAL&index=%d", path, index)
 	if strings.HasSuffix(path, "/") {
-		url += "&recurse"
+		query += "&recurse"
 	}
 	if c.conf.NamespaceID != "" {
-		url += "&nid=" + c.conf.NamespaceID
+		query += "&nid=" + c.conf.NamespaceID
 	}
 	if c.conf.AppID != "" {
-		url += "&uid=" + c.conf.AppID
+		query += "&uid=" + c.conf.AppID
 	}
 	defer func() {
 		if err != nil {
@@ -122,7 +177,12 @@ func (c *Consul) fetch(path string, index int64) (res []config.Spec, consulIndex
 			Value string
 		}
 	)
-	header, err = c.queryCli.Get(url, &items)
+	// 请求失败时换一个consul地址重试
+	err = c.endpoints.Do(http.CallBlockingRead, func(addr string) (err error) {
+		url = c.conf.scheme() + "://" + addr + query
+		header, err = c.queryCli.Get(url, &items)
+		return
+	})
 	if err != nil {
 		if errors.IsNotFound(err) {
 			err = nil
@@ -1,21 +1,24 @@
 package consul
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"net/http"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/tsftest"
 	"gopkg.in/yaml.v3"
 )
 
-func TestMain(m *testing.M) {
-	m.Run()
+var fake *tsftest.Consul
 
+func TestMain(m *testing.M) {
+	fake = tsftest.NewConsul()
+	code := m.Run()
+	fake.Close()
+	os.Exit(code)
 }
 
 const testContent1 = `destList:
@@ -32,7 +35,7 @@ func TestSimpleConfig(t *testing.T) {
 		t.FailNow()
 	}
 	config := New(&Config{
-		Address: "127.0.0.1:8500",
+		Address: fake.Addr(),
 	})
 	watcher := config.Subscribe("com/tencent/tsf")
 
@@ -101,35 +104,43 @@ func checkConfig(t *testing.T, watcher config.Watcher, expect string) {
 }
 
 func deleteKey(key string) error {
-	client := http.Client{Timeout: time.Second * 2}
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("http://127.0.0.1:8500/v1/kv/%s", key), nil)
-	if err != nil {
-		return err
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("response status (%d) not 200", resp.StatusCode)
-	}
+	fake.Delete(key)
 	return nil
 }
 
 func set(key string, value []byte) error {
-	client := http.Client{Timeout: time.Second * 2}
-	req, err := http.NewRequest("PUT", fmt.Sprintf("http://127.0.0.1:8500/v1/kv/%s", key), bytes.NewReader(value))
-	if err != nil {
-		return err
+	fake.Put(key, value)
+	return nil
+}
+
+func TestAddresses(t *testing.T) {
+	conf := &Config{Address: "127.0.0.1:8500", Addresses: []string{"127.0.0.2:8500", "127.0.0.1:8500"}}
+	if addrs := conf.addresses(); !reflect.DeepEqual(addrs, []string{"127.0.0.1:8500", "127.0.0.2:8500"}) {
+		t.Fatalf("unexpected addresses: %v", addrs)
+	}
+	if addrs := (&Config{Addresses: []string{"127.0.0.2:8500"}}).addresses(); !reflect.DeepEqual(addrs, []string{"127.0.0.2:8500"}) {
+		t.Fatalf("unexpected addresses: %v", addrs)
 	}
-	resp, err := client.Do(req)
+
+	// Address不可用时切换到Addresses中的地址
+	err := set("com/tencent/tsf/addresses", []byte(testContent1))
 	if err != nil {
-		return err
+		t.Fatalf("setConsul com/tencent/tsf/addresses failed!err:=%v", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("response status (%d) not 200", resp.StatusCode)
+	config := New(&Config{
+		Address:   "127.0.0.1:1",
+		Addresses: []string{fake.Addr()},
+	})
+	checkConfig(t, config.Subscribe("com/tencent/tsf/addresses"), testContent1)
+}
+
+func TestLateSubscribe(t *testing.T) {
+	err := set("com/tencent/tsf/late", []byte(testContent1))
+	if err != nil {
+		t.Fatalf("setConsul com/tencent/tsf/late failed!err:=%v", err)
 	}
-	return nil
+	config := New(&Config{Address: fake.Addr()})
+	checkConfig(t, config.Subscribe("com/tencent/tsf/late"), testContent1)
+	// 后订阅同一个key的watcher也能收到当前的配置
+	checkConfig(t, config.Subscribe("com/tencent/tsf/late"), testContent1)
 }
@@ -0,0 +1,51 @@
+package consul
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	xhttp "github.com/tencentyun/tsf-go/pkg/http"
+)
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "token" || r.URL.Query().Get("token") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprintf(w, `[{"Key":"com/tencent/tsf","Value":"%s"}]`, base64.StdEncoding.EncodeToString([]byte(testContent1)))
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	assert.Nil(t, err)
+
+	c := New(&Config{
+		Address: strings.TrimPrefix(srv.URL, "https://"),
+		Token:   "token",
+		TLS:     &xhttp.TLSConfig{CAFile: caFile},
+	})
+	specs, index, err := c.fetch("com/tencent/tsf", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), index)
+	assert.Equal(t, 1, len(specs))
+	assert.Equal(t, testContent1, string(specs[0].Data.Raw()))
+
+	// 未配置CA无法校验服务端证书
+	c = New(&Config{Address: strings.TrimPrefix(srv.URL, "https://"), Token: "token", Scheme: "https"})
+	_, _, err = c.fetch("com/tencent/tsf", 0)
+	assert.NotNil(t, err)
+}
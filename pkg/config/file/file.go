@@ -0,0 +1,139 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/config/memory"
+)
+
+var _ config.Source = &File{}
+
+// File is a directory backed config source, the consul style key is mapped to the file under directory,
+// e.g. route/<namespace>/data is read from <dir>/route/<namespace>/data or <dir>/route/<namespace>/data.yaml.
+type File struct {
+	dir     string
+	memory  *memory.Memory
+	watcher *fsnotify.Watcher
+}
+
+// New new a config source of directory, the files are reloaded on change.
+func New(dir string) (*File, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	f := &File{
+		dir:     dir,
+		memory:  memory.New(),
+		watcher: watcher,
+	}
+	if err = f.load(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go f.run()
+	return f, nil
+}
+
+// key returns the consul style key of file.
+func (f *File) key(path string) (string, bool) {
+	rel, err := filepath.Rel(f.dir, path)
+	if err != nil {
+		return "", false
+	}
+	base := filepath.Base(rel)
+	// 忽略隐藏文件以及编辑器的临时文件
+	if strings.HasPrefix(base, ".") || strings.HasSuffix(base, "~") {
+		return "", false
+	}
+	key := filepath.ToSlash(rel)
+	for _, ext := range []string{".yaml", ".yml"} {
+		key = strings.TrimSuffix(key, ext)
+	}
+	return key, true
+}
+
+// load reads all the files under directory into memory, and watches the directories.
+func (f *File) load() error {
+	kv := make(map[string][]byte)
+	err := filepath.Walk(f.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 文件在遍历时被删除
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			// fsnotify不支持递归监听，每个目录都需要添加
+			return f.watcher.Add(path)
+		}
+		key, ok := f.key(path)
+		if !ok {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		kv[key] = content
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range f.memory.Keys() {
+		if _, ok := kv[key]; !ok {
+			f.memory.Delete(key)
+		}
+	}
+	for key, value := range kv {
+		f.memory.Put(key, value)
+	}
+	return nil
+}
+
+func (f *File) run() {
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if err := f.load(); err != nil {
+				log.DefaultLog.Errorw("msg", "[config] reload config files failed!", "dir", f.dir, "event", event.String(), "err", err)
+			}
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.DefaultLog.Errorw("msg", "[config] watch config files failed!", "dir", f.dir, "err", err)
+		}
+	}
+}
+
+func (f *File) Subscribe(path string) config.Watcher {
+	return f.memory.Subscribe(path)
+}
+
+func (f *File) Get(ctx context.Context, path string) []config.Spec {
+	return f.memory.Get(ctx, path)
+}
+
+// Close stops watching the directory.
+func (f *File) Close() error {
+	return f.watcher.Close()
+}
@@ -0,0 +1,59 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/config"
+)
+
+func watch(t *testing.T, w config.Watcher) []config.Spec {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	specs, err := w.Watch(ctx)
+	assert.Nil(t, err)
+	return specs
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "route", "ns"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "route", "ns", "data.yaml"), []byte("a: 1"), 0644))
+
+	f, err := New(dir)
+	assert.Nil(t, err)
+	defer f.Close()
+	routes := f.Subscribe("route/ns/")
+	defer routes.Close()
+	specs := watch(t, routes)
+	assert.Equal(t, 1, len(specs))
+	assert.Equal(t, "route/ns/data", specs[0].Key)
+
+	// 新建目录及文件
+	auth := f.Subscribe("authority/ns/provider/data")
+	defer auth.Close()
+	assert.Equal(t, 0, len(watch(t, auth)))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "authority", "ns", "provider"), 0755))
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "authority", "ns", "provider", "data"), []byte("a: 2"), 0644))
+	// 创建及写入文件可能分多次推送
+	for i := 0; i < 3; i++ {
+		if specs = watch(t, auth); len(specs) > 0 && len(specs[0].Data.Raw()) > 0 {
+			break
+		}
+	}
+	assert.Equal(t, 1, len(specs))
+	var v struct{ A int }
+	assert.Nil(t, specs[0].Data.Unmarshal(&v))
+	assert.Equal(t, 2, v.A)
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "route", "ns", "data.yaml")))
+	assert.Equal(t, 0, len(watch(t, routes)))
+}
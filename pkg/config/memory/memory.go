@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/tencentyun/tsf-go/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+var _ config.Source = &Memory{}
+
+// Memory is an in-memory config source, mostly used in unit tests.
+type Memory struct {
+	lock    sync.RWMutex
+	kv      map[string][]byte
+	watcher map[*Watcher]struct{}
+}
+
+// New new an empty in-memory config source.
+func New() *Memory {
+	return &Memory{
+		kv:      make(map[string][]byte),
+		watcher: make(map[*Watcher]struct{}),
+	}
+}
+
+// Put sets the value of key, the watchers of key and its parent directories are notified if changed.
+func (m *Memory) Put(key string, value []byte) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if old, ok := m.kv[key]; ok && bytes.Equal(old, value) {
+		return
+	}
+	m.kv[key] = value
+	m.broadcast(key)
+}
+
+// Delete deletes the key.
+func (m *Memory) Delete(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.kv[key]; !ok {
+		return
+	}
+	delete(m.kv, key)
+	m.broadcast(key)
+}
+
+// Keys returns all the keys sorted.
+func (m *Memory) Keys() (keys []string) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for k := range m.kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func (m *Memory) broadcast(key string) {
+	for w := range m.watcher {
+		if !match(w.path, key) {
+			continue
+		}
+		select {
+		case w.event <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// match 如果path是以/结尾，则是目录，否则当作key处理
+func match(path, key string) bool {
+	if strings.HasSuffix(path, "/") {
+		return strings.HasPrefix(key, path)
+	}
+	return path == key
+}
+
+func (m *Memory) Subscribe(path string) config.Watcher {
+	w := &Watcher{
+		memory: m,
+		path:   path,
+		event:  make(chan struct{}, 1),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	// 第一次Watch立即返回当前的值
+	w.event <- struct{}{}
+	m.lock.Lock()
+	m.watcher[w] = struct{}{}
+	m.lock.Unlock()
+	return w
+}
+
+func (m *Memory) Get(ctx context.Context, path string) (specs []config.Spec) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for k, v := range m.kv {
+		if match(path, k) {
+			specs = append(specs, config.Spec{Key: k, Data: Raw(v)})
+		}
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Key < specs[j].Key
+	})
+	return
+}
+
+// Raw is the yaml content of config.
+type Raw []byte
+
+func (r Raw) Unmarshal(out interface{}) error {
+	if r == nil {
+		return nil
+	}
+	return yaml.Unmarshal(r, out)
+}
+
+func (r Raw) Raw() []byte {
+	if r == nil {
+		return nil
+	}
+	return r
+}
+
+type Watcher struct {
+	memory *Memory
+	path   string
+	event  chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (w *Watcher) Watch(ctx context.Context) (spec []config.Spec, err error) {
+	select {
+	case <-ctx.Done():
+		err = errors.GatewayTimeout(errors.UnknownReason, "")
+		return
+	case <-w.ctx.Done():
+		err = errors.ClientClosed(errors.UnknownReason, "")
+		return
+	case <-w.event:
+		spec = w.memory.Get(ctx, w.path)
+	}
+	return
+}
+
+func (w *Watcher) Close() {
+	select {
+	case <-w.ctx.Done():
+		return
+	default:
+	}
+	w.cancel()
+	w.memory.lock.Lock()
+	delete(w.memory.watcher, w)
+	w.memory.lock.Unlock()
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	m := New()
+	m.Put("route/ns/data", []byte("a: 1"))
+	dir := m.Subscribe("route/ns/")
+	key := m.Subscribe("route/ns/data")
+	defer dir.Close()
+
+	// 第一次Watch立即返回
+	specs, err := dir.Watch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(specs))
+	specs, err = key.Watch(context.Background())
+	assert.Nil(t, err)
+	var v struct{ A int }
+	assert.Nil(t, specs[0].Data.Unmarshal(&v))
+	assert.Equal(t, 1, v.A)
+
+	// 值没有变化不推送
+	m.Put("route/ns/data", []byte("a: 1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, err = key.Watch(ctx)
+	cancel()
+	assert.True(t, errors.IsGatewayTimeout(err))
+
+	m.Put("route/ns/other", []byte("a: 2"))
+	specs, err = dir.Watch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(specs))
+	assert.Equal(t, "route/ns/other", specs[1].Key)
+
+	m.Delete("route/ns/data")
+	specs, err = key.Watch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(specs))
+
+	key.Close()
+	_, err = key.Watch(context.Background())
+	assert.True(t, errors.IsClientClosed(err))
+}
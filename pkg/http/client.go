@@ -2,7 +2,9 @@ package http
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
@@ -13,12 +15,18 @@ import (
 )
 
 type Client struct {
-	cli *http.Client
+	cli    *http.Client
+	header http.Header
+	// tls配置加载失败时所有请求直接返回该错误
+	err error
 }
 
 type options struct {
 	timeout         time.Duration
 	maxConnsPerHost int
+	tlsConfig       *tls.Config
+	header          http.Header
+	err             error
 }
 
 // Option configures how we set up the client.
@@ -56,8 +64,36 @@ func WithMaxConnPerHost(max int) Option {
 	})
 }
 
+// WithTLSConfig returns a Option that configures the tls config for https requests.
+func WithTLSConfig(c *tls.Config) Option {
+	return newFuncOption(func(o *options) {
+		o.tlsConfig = c
+	})
+}
+
+// WithTLS returns a Option that loads the certificates of c for https requests,
+// if the certificates failed to load, every request of the client returns the error
+// instead of being sent without the requested tls config.
+func WithTLS(c *TLSConfig) Option {
+	return newFuncOption(func(o *options) {
+		conf, err := c.Build()
+		if err != nil {
+			o.err = fmt.Errorf("load tls config failed: %w", err)
+			return
+		}
+		o.tlsConfig = conf
+	})
+}
+
+// WithHeader returns a Option that configures a header sent with every request.
+func WithHeader(key, value string) Option {
+	return newFuncOption(func(o *options) {
+		o.header.Set(key, value)
+	})
+}
+
 func NewClient(optFunc ...Option) *Client {
-	opts := &options{}
+	opts := &options{header: make(http.Header)}
 	for _, f := range optFunc {
 		f.apply(opts)
 	}
@@ -73,11 +109,16 @@ func NewClient(optFunc ...Option) *Client {
 		ExpectContinueTimeout: 1 * time.Second,
 		MaxIdleConnsPerHost:   6,
 		MaxConnsPerHost:       opts.maxConnsPerHost,
+		TLSClientConfig:       opts.tlsConfig,
+	}
+	return &Client{
+		cli: &http.Client{
+			Timeout:   opts.timeout,
+			Transport: transport,
+		},
+		header: opts.header,
+		err:    opts.err,
 	}
-	return &Client{cli: &http.Client{
-		Timeout:   opts.timeout,
-		Transport: transport,
-	}}
 }
 
 // Get http get
@@ -106,6 +147,10 @@ func (c *Client) Do(method string, url string, reqBody interface{}, respBody int
 		body    io.Reader
 		req     *http.Request
 	)
+	if c.err != nil {
+		err = c.err
+		return
+	}
 	if reqBody != nil {
 		content, err = json.Marshal(reqBody)
 		if err != nil {
@@ -117,6 +162,9 @@ func (c *Client) Do(method string, url string, reqBody interface{}, respBody int
 	if err != nil {
 		return
 	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
 	if body != nil {
 		req.Header.Add("Content-Type", "application/json")
 	}
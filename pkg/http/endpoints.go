@@ -0,0 +1,181 @@
+package http
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+)
+
+const (
+	// 连续失败次数达到ejectFailures时摘除该地址
+	ejectFailures = 3
+	// 地址被摘除的冷却时间
+	ejectCooldown = 30 * time.Second
+	// 读请求失败时最多换地址重试的次数
+	maxRetries = 2
+	// 延迟滑动平均系数
+	latencyDecay = 0.3
+)
+
+// CallType is the type of call sent by Endpoints.Do.
+type CallType int
+
+const (
+	// CallWrite is not retried on other addresses.
+	CallWrite CallType = iota
+	// CallRead is idempotent and retried on other addresses if failed.
+	CallRead
+	// CallBlockingRead is a CallRead which may be blocked by server,
+	// e.g. consul blocking queries, the latency is not recorded.
+	CallBlockingRead
+)
+
+type endpoint struct {
+	addr       string
+	failures   int
+	latency    float64
+	ejectUntil time.Time
+}
+
+func (e *endpoint) score() float64 {
+	return e.latency * float64(e.failures+1)
+}
+
+// Endpoints picks the healthiest address from a list of server addresses,
+// the address is scored by the latency and consecutive failures,
+// and ejected for a cooldown period if it keeps failing.
+type Endpoints struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	now       func() time.Time
+}
+
+// NewEndpoints new an Endpoints of addresses.
+func NewEndpoints(addrs []string) *Endpoints {
+	e := &Endpoints{now: time.Now}
+	for _, addr := range addrs {
+		e.endpoints = append(e.endpoints, &endpoint{addr: addr})
+	}
+	return e
+}
+
+// Pick returns the healthiest address except the excluded ones,
+// the address ejected earliest is returned if all addresses are ejected.
+func (e *Endpoints) Pick(exclude ...string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	var healthy, ejected []*endpoint
+	for _, ep := range e.endpoints {
+		if contains(exclude, ep.addr) {
+			continue
+		}
+		if ep.ejectUntil.After(now) {
+			ejected = append(ejected, ep)
+			continue
+		}
+		if !ep.ejectUntil.IsZero() {
+			// 冷却结束，重新参与选择
+			ep.failures = 0
+			ep.ejectUntil = time.Time{}
+		}
+		healthy = append(healthy, ep)
+	}
+	switch len(healthy) {
+	case 0:
+		var picked *endpoint
+		for _, ep := range ejected {
+			if picked == nil || ep.ejectUntil.Before(picked.ejectUntil) {
+				picked = ep
+			}
+		}
+		if picked == nil {
+			return ""
+		}
+		return picked.addr
+	case 1:
+		return healthy[0].addr
+	}
+	// p2c: 随机选两个地址，取分数低的
+	a := rand.Intn(len(healthy))
+	b := rand.Intn(len(healthy) - 1)
+	if b >= a {
+		b++
+	}
+	if healthy[b].score() < healthy[a].score() {
+		a = b
+	}
+	return healthy[a].addr
+}
+
+// Report reports the result of a call to the address,
+// the latency is ignored if it is not positive.
+func (e *Endpoints) Report(addr string, err error, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, ep := range e.endpoints {
+		if ep.addr != addr {
+			continue
+		}
+		if latency > 0 {
+			if ep.latency == 0 {
+				ep.latency = float64(latency)
+			} else {
+				ep.latency = ep.latency*(1-latencyDecay) + float64(latency)*latencyDecay
+			}
+		}
+		if !failed(err) {
+			ep.failures = 0
+			ep.ejectUntil = time.Time{}
+			return
+		}
+		ep.failures++
+		if ep.failures >= ejectFailures {
+			ep.ejectUntil = e.now().Add(ejectCooldown)
+		}
+		return
+	}
+}
+
+// Do calls f with the healthiest address,
+// the read call is retried on other addresses if failed.
+func (e *Endpoints) Do(typ CallType, f func(addr string) error) (err error) {
+	var tried []string
+	for {
+		addr := e.Pick(tried...)
+		if addr == "" {
+			if len(tried) == 0 {
+				err = errors.ServiceUnavailable(errors.UnknownReason, "no endpoint available")
+			}
+			return
+		}
+		start := time.Now()
+		err = f(addr)
+		var latency time.Duration
+		if typ != CallBlockingRead {
+			latency = time.Since(start)
+		}
+		e.Report(addr, err, latency)
+		tried = append(tried, addr)
+		if !failed(err) || typ == CallWrite || len(tried) > maxRetries {
+			return
+		}
+	}
+}
+
+// failed returns whether the error is caused by the server or network,
+// e.g. 404 is a valid response of consul.
+func failed(err error) bool {
+	return err != nil && errors.FromError(err).GetCode() >= 500
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
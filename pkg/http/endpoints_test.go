@@ -0,0 +1,88 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointsEject(t *testing.T) {
+	now := time.Now()
+	e := NewEndpoints([]string{"a", "b"})
+	e.now = func() time.Time { return now }
+
+	for i := 0; i < ejectFailures; i++ {
+		e.Report("a", errors.ServiceUnavailable("", ""), time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "b", e.Pick())
+	}
+	// 所有地址都被摘除时选择最早摘除的地址
+	assert.Equal(t, "a", e.Pick("b"))
+	now = now.Add(time.Second)
+	for i := 0; i < ejectFailures; i++ {
+		e.Report("b", errors.ServiceUnavailable("", ""), time.Millisecond)
+	}
+	assert.Equal(t, "a", e.Pick())
+	assert.Equal(t, "", e.Pick("a", "b"))
+
+	// 冷却时间结束后恢复
+	now = now.Add(ejectCooldown)
+	picked := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		picked[e.Pick()] = true
+	}
+	assert.Equal(t, 2, len(picked))
+
+	// 404不算失败
+	for i := 0; i < ejectFailures; i++ {
+		e.Report("a", errors.NotFound("", ""), time.Millisecond)
+	}
+	assert.Equal(t, 0, e.endpoints[0].failures)
+}
+
+func TestEndpointsLatency(t *testing.T) {
+	e := NewEndpoints([]string{"a", "b"})
+	e.Report("a", nil, 100*time.Millisecond)
+	e.Report("b", nil, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "b", e.Pick())
+	}
+}
+
+func TestEndpointsDo(t *testing.T) {
+	e := NewEndpoints([]string{"a", "b", "c", "d"})
+	var calls int
+	fail := func(addr string) error {
+		calls++
+		return errors.ServiceUnavailable("", "")
+	}
+	// 读请求最多重试maxRetries次
+	assert.NotNil(t, e.Do(CallRead, fail))
+	assert.Equal(t, maxRetries+1, calls)
+
+	calls = 0
+	assert.NotNil(t, e.Do(CallWrite, fail))
+	assert.Equal(t, 1, calls)
+
+	e = NewEndpoints([]string{"a", "b"})
+	var tried []string
+	err := e.Do(CallBlockingRead, func(addr string) error {
+		tried = append(tried, addr)
+		if len(tried) == 1 {
+			return errors.ServiceUnavailable("", "")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tried))
+	assert.NotEqual(t, tried[0], tried[1])
+	// 阻塞查询不统计延迟
+	for _, ep := range e.endpoints {
+		assert.Equal(t, float64(0), ep.latency)
+	}
+
+	assert.NotNil(t, NewEndpoints(nil).Do(CallRead, func(addr string) error { return nil }))
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+)
+
+// TLSConfig https client config
+type TLSConfig struct {
+	// 校验服务端证书的CA文件，为空时使用系统CA
+	CAFile string
+	// 客户端证书及私钥，服务端开启双向认证时需要
+	CertFile string
+	KeyFile  string
+	// 不校验服务端证书
+	InsecureSkipVerify bool
+}
+
+// ConsulTLSConfig returns the tls config of consul from env,
+// nil is returned if consul is not accessed by https.
+func ConsulTLSConfig() *TLSConfig {
+	if env.ConsulScheme() != "https" {
+		return nil
+	}
+	return &TLSConfig{
+		CAFile:             env.ConsulCAFile(),
+		CertFile:           env.ConsulCertFile(),
+		KeyFile:            env.ConsulKeyFile(),
+		InsecureSkipVerify: env.ConsulInsecureSkipVerify(),
+	}
+}
+
+// Build loads the ca and client certificates.
+func (c *TLSConfig) Build() (*tls.Config, error) {
+	conf := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificate found in ca file %s", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
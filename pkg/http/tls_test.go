@@ -0,0 +1,117 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, file, typ string, b []byte) {
+	err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600)
+	assert.Nil(t, err)
+}
+
+// newClientCert generates a self-signed client certificate.
+func newClientCert(t *testing.T, dir string) (cert *x509.Certificate, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tsf-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err = x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return
+}
+
+func TestTLSClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	clientCert, certFile, keyFile := newClientCert(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.Header.Get("X-Consul-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"cn":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", srv.Certificate().Raw)
+
+	// 未配置客户端证书
+	tlsConf, err := (&TLSConfig{CAFile: caFile}).Build()
+	assert.Nil(t, err)
+	_, err = NewClient(WithTLSConfig(tlsConf), WithHeader("X-Consul-Token", "token")).Get(srv.URL, nil)
+	assert.NotNil(t, err)
+
+	tlsConf, err = (&TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}).Build()
+	assert.Nil(t, err)
+	var resp struct {
+		CN string `json:"cn"`
+	}
+	_, err = NewClient(WithTLSConfig(tlsConf), WithHeader("X-Consul-Token", "token")).Get(srv.URL, &resp)
+	assert.Nil(t, err)
+	assert.Equal(t, "tsf-client", resp.CN)
+
+	_, err = NewClient(WithTLSConfig(tlsConf)).Get(srv.URL, nil)
+	assert.NotNil(t, err)
+
+	_, err = (&TLSConfig{CAFile: certFile + ".notexist"}).Build()
+	assert.NotNil(t, err)
+
+	// 证书加载成功
+	_, err = NewClient(WithTLS(&TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}), WithHeader("X-Consul-Token", "token")).Get(srv.URL, &resp)
+	assert.Nil(t, err)
+}
+
+func TestTLSLoadFailed(t *testing.T) {
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	// 证书加载失败时不发送请求，token不会被明文发送
+	cli := NewClient(WithTLS(&TLSConfig{CAFile: "notexist.pem"}), WithHeader("X-Consul-Token", "token"))
+	_, err := cli.Get(srv.URL, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "load tls config failed")
+	assert.NotNil(t, cli.Put(srv.URL, nil, nil))
+	assert.Equal(t, 0, received)
+}
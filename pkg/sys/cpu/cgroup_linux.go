@@ -0,0 +1,42 @@
+//go:build linux
+// +build linux
+
+package cpu
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// quota returns the cpu cores limited by cgroup v2 or v1, 0 means unlimited.
+func quota() float64 {
+	return cgroupQuota(cgroupRoot)
+}
+
+func cgroupQuota(root string) float64 {
+	var quota, period float64
+	// cgroup v2: "max 100000" or "200000 100000"
+	if fields := strings.Fields(readFile(filepath.Join(root, "cpu.max"))); len(fields) == 2 {
+		quota, _ = strconv.ParseFloat(fields[0], 64)
+		period, _ = strconv.ParseFloat(fields[1], 64)
+	} else {
+		quota, _ = strconv.ParseFloat(readFile(filepath.Join(root, "cpu/cpu.cfs_quota_us")), 64)
+		period, _ = strconv.ParseFloat(readFile(filepath.Join(root, "cpu/cpu.cfs_period_us")), 64)
+	}
+	if quota > 0 && period > 0 {
+		return quota / period
+	}
+	return 0
+}
+
+func readFile(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package cpu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCgroupQuota(t *testing.T) {
+	write := func(root, name, content string) {
+		path := filepath.Join(root, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	v2, err := ioutil.TempDir("", "cgroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(v2)
+	write(v2, "cpu.max", "max 100000\n")
+	assert.Equal(t, 0.0, cgroupQuota(v2))
+	write(v2, "cpu.max", "150000 100000\n")
+	assert.Equal(t, 1.5, cgroupQuota(v2))
+
+	v1, err := ioutil.TempDir("", "cgroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(v1)
+	write(v1, "cpu/cpu.cfs_quota_us", "-1\n")
+	write(v1, "cpu/cpu.cfs_period_us", "100000\n")
+	assert.Equal(t, 0.0, cgroupQuota(v1))
+	write(v1, "cpu/cpu.cfs_quota_us", "200000\n")
+	assert.Equal(t, 2.0, cgroupQuota(v1))
+
+	assert.Equal(t, 0.0, cgroupQuota(filepath.Join(v1, "none")))
+}
+
+func TestCores(t *testing.T) {
+	assert.True(t, Cores() > 0)
+}
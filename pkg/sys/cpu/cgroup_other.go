@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package cpu
+
+// quota is only supported on linux.
+func quota() float64 {
+	return 0
+}
@@ -0,0 +1,67 @@
+package cpu
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	interval = time.Millisecond * 250
+	// usage = usage*decay + cur*(1-decay)
+	decay = 0.95
+)
+
+var (
+	once  sync.Once
+	usage uint64
+)
+
+// Usage returns the smoothed cpu usage of current process in permille,
+// 1000 means all cores limited by cgroup (or all cores of the host if unlimited) are busy.
+func Usage() uint64 {
+	once.Do(func() {
+		go sample()
+	})
+	return atomic.LoadUint64(&usage)
+}
+
+// Quota returns the cpu cores limited by cgroup cpu quota, 0 means unlimited.
+func Quota() float64 {
+	return quota()
+}
+
+// Cores returns the cpu cores available to current process,
+// it is the cgroup cpu quota if set, otherwise runtime.NumCPU().
+func Cores() float64 {
+	cores := float64(runtime.NumCPU())
+	if q := quota(); q > 0 && q < cores {
+		cores = q
+	}
+	return cores
+}
+
+func sample() {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// 容器的cpu配额一般不会在运行时变化
+	cores := Cores()
+	lastCPU := processTime()
+	lastTs := time.Now()
+	for now := range ticker.C {
+		cur := processTime()
+		elapsed := now.Sub(lastTs)
+		if elapsed <= 0 || cur < lastCPU {
+			lastCPU, lastTs = cur, now
+			continue
+		}
+		permille := float64(cur-lastCPU) / float64(elapsed) / cores * 1000
+		if permille > 1000 {
+			permille = 1000
+		}
+		old := atomic.LoadUint64(&usage)
+		atomic.StoreUint64(&usage, uint64(float64(old)*decay+permille*(1-decay)))
+		lastCPU, lastTs = cur, now
+	}
+}
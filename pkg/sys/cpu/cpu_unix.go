@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package cpu
+
+import (
+	"syscall"
+	"time"
+)
+
+// processTime returns the user and system cpu time consumed by current process.
+func processTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
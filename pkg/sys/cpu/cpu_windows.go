@@ -0,0 +1,8 @@
+package cpu
+
+import "time"
+
+// processTime is not supported on windows, the usage is always 0.
+func processTime() time.Duration {
+	return 0
+}
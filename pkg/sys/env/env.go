@@ -1,8 +1,10 @@
 package env
 
 import (
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"strconv"
@@ -19,6 +21,16 @@ var (
 	consulAddressList string
 	consulHost        string
 	consulPort        int
+	consulProtect     int
+	consulSnapshot    string
+	consulScheme      string
+	consulCAFile      string
+	consulCertFile    string
+	consulKeyFile     string
+	consulSkipVerify  bool
+	configFile        string
+	configKey         string
+	configKeyFile     string
 	instanceId        string
 	token             string
 	localIP           string
@@ -35,6 +47,8 @@ var (
 	pprofPort         int
 	disableGops       bool
 	disablePprof      bool
+	enablePrometheus  bool
+	monitorExtended   bool
 
 	sshUser    string
 	sshHost    string
@@ -105,6 +119,65 @@ func ConsulAddressList() []string {
 	return strings.Split(consulAddressList, ",")
 }
 
+// ConsulProtectPercent 服务发现下线实例比例超过该值时保留上一次的实例列表
+func ConsulProtectPercent() int {
+	return consulProtect
+}
+
+// ConsulSnapshotDir 服务发现实例列表的本地快照目录
+func ConsulSnapshotDir() string {
+	return consulSnapshot
+}
+
+// ConfigFile 本地覆盖配置文件，优先级高于TSF下发的配置
+func ConfigFile() string {
+	return configFile
+}
+
+// ConfigEncryptKey 配置加密密钥，base64编码，优先使用tsf_config_encrypt_key，其次从tsf_config_encrypt_key_file文件中读取
+func ConfigEncryptKey() ([]byte, error) {
+	key := configKey
+	if key == "" && configKeyFile != "" {
+		content, err := ioutil.ReadFile(configKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = strings.TrimSpace(string(content))
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(key)
+}
+
+// ConsulScheme consul的访问协议: http或https
+func ConsulScheme() string {
+	if consulScheme == "" {
+		return "http"
+	}
+	return consulScheme
+}
+
+// ConsulCAFile 校验consul服务端证书的CA文件
+func ConsulCAFile() string {
+	return consulCAFile
+}
+
+// ConsulCertFile 访问consul的客户端证书
+func ConsulCertFile() string {
+	return consulCertFile
+}
+
+// ConsulKeyFile 访问consul的客户端证书私钥
+func ConsulKeyFile() string {
+	return consulKeyFile
+}
+
+// ConsulInsecureSkipVerify 不校验consul服务端证书
+func ConsulInsecureSkipVerify() bool {
+	return consulSkipVerify
+}
+
 func InstanceId() string {
 	if instanceId == "" {
 		hostname, err := os.Hostname()
@@ -207,6 +280,16 @@ func DisableDisablePprof() bool {
 	return disablePprof
 }
 
+// EnablePrometheus 在pprof端口的/metrics上暴露prometheus指标
+func EnablePrometheus() bool {
+	return enablePrometheus
+}
+
+// MonitorExtended 在TSF调用日志中上报分位耗时、最大耗时和熔断拒绝数，默认关闭以保持原有的上报格式
+func MonitorExtended() bool {
+	return monitorExtended
+}
+
 func PprofPort() int {
 	if pprofPort == 0 {
 		return 47077
@@ -229,6 +312,16 @@ func init() {
 	flag.StringVar(&consulHost, "tsf_consul_ip", os.Getenv("tsf_consul_ip"), "-tsf_consul_ip 127.0.0.1")
 	flag.StringVar(&consulAddressList, "tsf_consul_list", os.Getenv("tsf_consul_list"), "-tsf_consul_list 127.0.0.1:8080")
 	flag.IntVar(&consulPort, "tsf_consul_port", parseInt(os.Getenv("tsf_consul_port")), "-tsf_consul_port 85000")
+	flag.IntVar(&consulProtect, "tsf_consul_protect_percent", parseInt(os.Getenv("tsf_consul_protect_percent")), "-tsf_consul_protect_percent 50")
+	flag.StringVar(&consulSnapshot, "tsf_consul_snapshot_dir", os.Getenv("tsf_consul_snapshot_dir"), "-tsf_consul_snapshot_dir ./snapshot")
+	flag.StringVar(&consulScheme, "tsf_consul_scheme", os.Getenv("tsf_consul_scheme"), "-tsf_consul_scheme https")
+	flag.StringVar(&consulCAFile, "tsf_consul_ca_file", os.Getenv("tsf_consul_ca_file"), "-tsf_consul_ca_file ./ca.pem")
+	flag.StringVar(&consulCertFile, "tsf_consul_cert_file", os.Getenv("tsf_consul_cert_file"), "-tsf_consul_cert_file ./client.pem")
+	flag.StringVar(&consulKeyFile, "tsf_consul_key_file", os.Getenv("tsf_consul_key_file"), "-tsf_consul_key_file ./client-key.pem")
+	flag.BoolVar(&consulSkipVerify, "tsf_consul_insecure_skip_verify", parseBool(os.Getenv("tsf_consul_insecure_skip_verify")), "-tsf_consul_insecure_skip_verify false")
+	flag.StringVar(&configFile, "tsf_config_file", os.Getenv("tsf_config_file"), "-tsf_config_file ./config.yaml")
+	flag.StringVar(&configKey, "tsf_config_encrypt_key", os.Getenv("tsf_config_encrypt_key"), "-tsf_config_encrypt_key base64key")
+	flag.StringVar(&configKeyFile, "tsf_config_encrypt_key_file", os.Getenv("tsf_config_encrypt_key_file"), "-tsf_config_encrypt_key_file ./config.key")
 	flag.StringVar(&instanceId, "tsf_instance_id", os.Getenv("tsf_instance_id"), "-tsf_instance_id xxx")
 	flag.StringVar(&token, "tsf_token", os.Getenv("tsf_token"), "-tsf_token xxx")
 	flag.StringVar(&localIP, "tsf_local_ip", os.Getenv("tsf_local_ip"), "-tsf_local_ip 127.0.0.1")
@@ -243,6 +336,8 @@ func init() {
 	flag.BoolVar(&disableGrpcHttp, "tsf_disable_grpc_http", parseBool(os.Getenv("tsf_disable_grpc_http")), "-tsf_disable_grpc_http false")
 	flag.BoolVar(&disableGops, "tsf_disable_gops", parseBool(os.Getenv("tsf_disable_gops")), "-tsf_disable_gops false")
 	flag.BoolVar(&disablePprof, "tsf_disable_pprof", parseBool(os.Getenv("tsf_disable_pprof")), "-tsf_disable_pprof false")
+	flag.BoolVar(&enablePrometheus, "tsf_enable_prometheus", parseBool(os.Getenv("tsf_enable_prometheus")), "-tsf_enable_prometheus false")
+	flag.BoolVar(&monitorExtended, "tsf_monitor_extended", parseBool(os.Getenv("tsf_monitor_extended")), "-tsf_monitor_extended false")
 	flag.IntVar(&pprofPort, "tsf_pprof_port", parseInt(os.Getenv("tsf_pprof_port")), "-tsf_pprof_port 47077")
 	flag.IntVar(&gopsPort, "tsf_gops_port", parseInt(os.Getenv("tsf_gops_port")), "-tsf_gops_port 46066")
 
@@ -1,7 +1,6 @@
 package metrics
 
 import (
-	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,7 +9,8 @@ import (
 	"github.com/google/gops/agent"
 	"github.com/tencentyun/tsf-go/log"
 	"github.com/tencentyun/tsf-go/pkg/sys/env"
-	"go.uber.org/zap"
+	"github.com/tencentyun/tsf-go/pkg/sys/monitor"
+	"github.com/tencentyun/tsf-go/pkg/sys/monitor/prometheus"
 )
 
 func StartAgent() {
@@ -26,6 +26,11 @@ func startPprof() {
 		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 		mux.HandleFunc("/debug/pprof/trace", pprof.Symbol)
+		if env.EnablePrometheus() {
+			sink := prometheus.New()
+			monitor.AddSink(sink)
+			mux.Handle("/metrics", sink.Handler())
+		}
 
 		addr := fmt.Sprintf(":%d", env.PprofPort())
 
@@ -38,7 +43,7 @@ func startPprof() {
 			Handler: mux,
 			Addr:    addr,
 		}
-		log.DefaultLog.Debugf("pprof http server start serve. To disable it,set tsf_disable_pprof=true", zap.String("addr", addr))
+		log.DefaultLog.Debugw("msg", "pprof http server start serve. To disable it,set tsf_disable_pprof=true", "addr", addr)
 		if err = server.Serve(lis); err != nil {
 			log.DefaultLog.Errorf("pprof server serve  err: %v", err)
 			return
@@ -49,7 +54,7 @@ func startPprof() {
 func startGops() {
 	if !env.DisableDisableGops() {
 		addr := fmt.Sprintf(":%d", env.GopsPort())
-		log.DefaultLog.Debug(context.Background(), "gops agent start serve.  To disable it,set tsf_disable_gops=true", zap.String("addr", addr))
+		log.DefaultLog.Debugw("msg", "gops agent start serve.  To disable it,set tsf_disable_gops=true", "addr", addr)
 		if err := agent.Listen(agent.Options{Addr: addr}); err != nil {
 			log.DefaultLog.Errorf("gops agent.Listen %s err: %v", addr, err)
 			return
@@ -2,130 +2,197 @@ package monitor
 
 import (
 	"encoding/json"
+	"net/http"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+	"google.golang.org/grpc/codes"
 )
 
+// 分片数，减少高并发下记录时的锁竞争
+const shardCount = 32
+
+// 耗时分桶的上界，最后一个桶是2000ms以上
+var rangeBounds = [10]time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	300 * time.Millisecond,
+	400 * time.Millisecond,
+	500 * time.Millisecond,
+	800 * time.Millisecond,
+	1200 * time.Millisecond,
+	1600 * time.Millisecond,
+	2000 * time.Millisecond,
+}
+
 var monitor *Monitor
 
 func init() {
 	monitor = New()
+	sinks.Store([]Sink{monitor})
 }
 
 func New() *Monitor {
 	m := &Monitor{
-		current: make(map[string][]*Stat),
+		done: make(chan struct{}),
+	}
+	for i := range m.shards {
+		m.shards[i].current = make(map[statKey]*aggregate)
 	}
 	go m.run()
 	return m
 }
 
+// Close stops the default monitor and dumps the stats not reported yet.
+func Close() {
+	monitor.Close()
+}
+
+// Monitor aggregates the stats by caller and callee in place, and writes them to the TSF invocation logs every minute.
 type Monitor struct {
-	current map[string][]*Stat
+	shards [shardCount]shard
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type shard struct {
 	lock    sync.Mutex
+	current map[statKey]*aggregate
 }
 
-func (m *Monitor) saveStat(s *Stat) {
-	hc := s.HashCode()
-	m.lock.Lock()
-	defer m.lock.Unlock()
-	m.current[hc] = append(m.current[hc], s)
+// statKey 与Stat.HashCode对应，用结构体作为key避免每次记录时拼接字符串
+type statKey struct {
+	category        string
+	kind            string
+	localService    string
+	localInterface  string
+	remoteService   string
+	remoteInterface string
+	hasRemote       bool
 }
 
-func (m *Monitor) run() {
-	ticker := time.NewTicker(time.Second * 60)
-	defer ticker.Stop()
-	for {
-		<-ticker.C
-		var old map[string][]*Stat
-		m.lock.Lock()
-		old = m.current
-		m.current = make(map[string][]*Stat)
-		m.lock.Unlock()
-		go m.dump(old)
+func (k *statKey) hash() uint32 {
+	// fnv-1a
+	h := uint32(2166136261)
+	for _, s := range [...]string{k.category, k.kind, k.localService, k.localInterface, k.remoteService, k.remoteInterface} {
+		for i := 0; i < len(s); i++ {
+			h ^= uint32(s[i])
+			h *= 16777619
+		}
 	}
+	return h
+}
+
+// aggregate 是一个周期内同一个key的统计结果
+type aggregate struct {
+	category   string
+	kind       string
+	local      *Endpoint
+	remote     *Endpoint
+	count      int64
+	sum        float64
+	statusCode map[int]int
+	rangeMs    [len(rangeBounds) + 1]int64
+	latency    *sketch
 }
 
-func (m *Monitor) dump(old map[string][]*Stat) {
-	for _, stats := range old {
-		if len(stats) == 0 {
-			continue
+func (a *aggregate) add(s *Stat) {
+	dur := s.End.Sub(s.Begin)
+	ms := float64(dur) / float64(time.Millisecond)
+	a.count++
+	a.sum += ms
+	a.statusCode[s.StatusCode]++
+	a.rangeMs[rangeIndex(dur)]++
+	a.latency.add(ms)
+}
+
+func rangeIndex(dur time.Duration) int {
+	for i, bound := range rangeBounds {
+		if dur <= bound {
+			return i
 		}
-		statusCode := make(map[int]int)
-		var rangeMs [11]int64
-		var sum float64
-		for _, stat := range stats {
-			statusCode[stat.StatusCode] = statusCode[stat.StatusCode] + 1
-			dur := stat.End.Sub(stat.Begin)
-			sum += float64(dur) / float64(time.Millisecond)
-			if dur <= time.Millisecond*50 {
-				rangeMs[0]++
-			} else if dur <= time.Millisecond*100 {
-				rangeMs[1]++
-			} else if dur <= time.Millisecond*200 {
-				rangeMs[2]++
-			} else if dur <= time.Millisecond*300 {
-				rangeMs[3]++
-			} else if dur <= time.Millisecond*400 {
-				rangeMs[4]++
-			} else if dur <= time.Millisecond*500 {
-				rangeMs[5]++
-			} else if dur <= time.Millisecond*800 {
-				rangeMs[6]++
-			} else if dur <= time.Millisecond*1200 {
-				rangeMs[7]++
-			} else if dur <= time.Millisecond*1600 {
-				rangeMs[8]++
-			} else if dur <= time.Millisecond*2000 {
-				rangeMs[9]++
-			} else {
-				rangeMs[10]++
-			}
+	}
+	return len(rangeBounds)
+}
+
+// Close stops the monitor and dumps the stats not reported yet.
+func (m *Monitor) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.dump(m.swap())
+		logger.Sync()
+	})
+}
+
+// Record aggregates the stat into the current period.
+func (m *Monitor) Record(s *Stat) {
+	key := statKey{
+		category:       s.Category,
+		kind:           s.Kind,
+		localService:   s.Local.ServiceName,
+		localInterface: s.Local.InterfaceName,
+	}
+	if s.Remote != nil {
+		key.remoteService = s.Remote.ServiceName
+		key.remoteInterface = s.Remote.InterfaceName
+		key.hasRemote = true
+	}
+	sh := &m.shards[key.hash()%shardCount]
+	sh.lock.Lock()
+	agg, ok := sh.current[key]
+	if !ok {
+		agg = &aggregate{
+			category:   s.Category,
+			kind:       s.Kind,
+			local:      s.Local,
+			remote:     s.Remote,
+			statusCode: make(map[int]int),
+			latency:    newSketch(),
 		}
-		count := len(stats)
-		avg := sum / float64(count)
-		var statusCodes []StatusCode
-		var statusSerial StatusSerial
-		for code, num := range statusCode {
-			statusCodes = append(statusCodes, StatusCode{Code: strconv.FormatInt(int64(code), 10), Amount: int64(num)})
-			if code == 200 {
-				statusSerial.Successful += num
-			} else if code == 400 || code == 499 {
-				statusSerial.ClientErr += num
-			} else if code == 500 {
-				statusSerial.ServerErr += num
-			} else if code == 503 || code == 429 || code == 444 {
-				statusSerial.Unavailable += num
-			} else if code == 504 {
-				statusSerial.Timeout += num
-			} else {
-				statusSerial.OtherErr += num
-			}
+		sh.current[key] = agg
+	}
+	agg.add(s)
+	sh.lock.Unlock()
+}
+
+// swap 取出当前周期的统计结果并开始新的周期
+func (m *Monitor) swap() (aggs []*aggregate) {
+	for i := range m.shards {
+		sh := &m.shards[i]
+		sh.lock.Lock()
+		old := sh.current
+		sh.current = make(map[statKey]*aggregate, len(old))
+		sh.lock.Unlock()
+		for _, agg := range old {
+			aggs = append(aggs, agg)
 		}
-		invocation := Invocation{
-			SumAmount:  int64(count),
-			StatusCode: statusCodes,
-			Duration: Duration{
-				Avg:     avg,
-				Sum:     sum,
-				Range50: rangeMs[0],
-			},
-			StatusSerial: statusSerial,
+	}
+	return
+}
+
+func (m *Monitor) run() {
+	ticker := time.NewTicker(time.Second * 60)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
 		}
+		go m.dump(m.swap())
+	}
+}
 
-		var metric MetricItem
-		stat := stats[0]
-		metric.Kind = stat.Kind
-		metric.Cateory = stat.Category
-		metric.Timestamp = time.Now().Unix()
-		metric.Timestamp = metric.Timestamp - metric.Timestamp%60
-		metric.Period = 60
-		metric.Local = stat.Local
-		metric.Remote = stat.Remote
-		metric.Invocation = invocation
+func (m *Monitor) dump(aggs []*aggregate) {
+	now := time.Now().Unix()
+	for _, agg := range aggs {
+		metric := agg.metric(now)
 		content, err := json.Marshal(metric)
 		if err != nil {
 			log.DefaultLog.Errorf("Monitor Marshal failed!metric:%v", metric)
@@ -135,6 +202,59 @@ func (m *Monitor) dump(old map[string][]*Stat) {
 	}
 }
 
+func (a *aggregate) metric(now int64) MetricItem {
+	var statusCodes []StatusCode
+	var statusSerial StatusSerial
+	codes := make([]int, 0, len(a.statusCode))
+	for code := range a.statusCode {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		num := a.statusCode[code]
+		statusCodes = append(statusCodes, StatusCode{Code: strconv.FormatInt(int64(code), 10), Amount: int64(num)})
+		statusSerial.add(code, num)
+	}
+	invocation := Invocation{
+		SumAmount:  a.count,
+		StatusCode: statusCodes,
+		Duration: Duration{
+			Avg:       a.sum / float64(a.count),
+			Sum:       a.sum,
+			Range50:   a.rangeMs[0],
+			Range100:  a.rangeMs[1],
+			Range200:  a.rangeMs[2],
+			Range300:  a.rangeMs[3],
+			Range400:  a.rangeMs[4],
+			Range500:  a.rangeMs[5],
+			Range800:  a.rangeMs[6],
+			Range1200: a.rangeMs[7],
+			Range1600: a.rangeMs[8],
+			Range2000: a.rangeMs[9],
+			RangeInf:  a.rangeMs[10],
+		},
+		StatusSerial: statusSerial,
+	}
+	// 扩展字段默认不上报，保持TSF调用日志原有的格式
+	if env.MonitorExtended() {
+		d := &invocation.Duration
+		d.P50, d.P90, d.P99, d.Max = a.latency.quantile(0.5), a.latency.quantile(0.9), a.latency.quantile(0.99), a.latency.max
+	} else {
+		invocation.StatusSerial.OtherErr += invocation.StatusSerial.BreakerOpen
+		invocation.StatusSerial.BreakerOpen = 0
+	}
+
+	var metric MetricItem
+	metric.Kind = a.kind
+	metric.Cateory = a.category
+	metric.Timestamp = now - now%60
+	metric.Period = 60
+	metric.Local = a.local
+	metric.Remote = a.remote
+	metric.Invocation = invocation
+	return metric
+}
+
 type MetricItem struct {
 	Cateory    string     `json:"category"`
 	Kind       string     `json:"kind"`
@@ -162,6 +282,58 @@ type StatusSerial struct {
 	Timeout       int `json:"timeout_error"`
 	Unavailable   int `json:"unavailable_error"`
 	OtherErr      int `json:"other_error"`
+	BreakerOpen   int `json:"circuit_breaker_error,omitempty"`
+}
+
+// add classifies the status code, which is usually a http status code converted from kratos error,
+// the grpc codes (0-16) are also accepted.
+func (s *StatusSerial) add(code, num int) {
+	if code >= 0 && code <= int(codes.Unauthenticated) {
+		code = grpcToHTTP[code]
+	}
+	switch {
+	case code == StatusBreakerOpen:
+		s.BreakerOpen += num
+	case code >= 100 && code < 200:
+		s.Informational += num
+	case code >= 200 && code < 300:
+		s.Successful += num
+	case code >= 300 && code < 400:
+		s.Redirection += num
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		s.Timeout += num
+	case code == http.StatusTooManyRequests || code == 444 || code == http.StatusServiceUnavailable:
+		s.Unavailable += num
+	case code == http.StatusBadGateway:
+		s.ConnErr += num
+	case code >= 400 && code < 500:
+		s.ClientErr += num
+	case code >= 500 && code < 600:
+		s.ServerErr += num
+	default:
+		s.OtherErr += num
+	}
+}
+
+// grpcToHTTP 与kratos的grpc错误码转换保持一致
+var grpcToHTTP = [...]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.Unknown:            http.StatusInternalServerError,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Internal:           http.StatusInternalServerError,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DataLoss:           http.StatusInternalServerError,
+	codes.Unauthenticated:    http.StatusUnauthorized,
 }
 
 type StatusCode struct {
@@ -172,6 +344,10 @@ type StatusCode struct {
 type Duration struct {
 	Avg       float64 `json:"avg_ms"`
 	Sum       float64 `json:"sum_ms"`
+	P50       float64 `json:"p50_ms,omitempty"`
+	P90       float64 `json:"p90_ms,omitempty"`
+	P99       float64 `json:"p99_ms,omitempty"`
+	Max       float64 `json:"max_ms,omitempty"`
 	Range50   int64   `json:"range_50_ms,omitempty"`
 	Range100  int64   `json:"range_50_100_ms,omitempty"`
 	Range200  int64   `json:"range_100_200_ms,omitempty"`
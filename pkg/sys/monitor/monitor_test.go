@@ -0,0 +1,313 @@
+package monitor
+
+import (
+	"encoding/json"
+	"flag"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMonitor() *Monitor {
+	m := &Monitor{done: make(chan struct{})}
+	for i := range m.shards {
+		m.shards[i].current = make(map[statKey]*aggregate)
+	}
+	return m
+}
+
+// extended 开启扩展字段的上报，返回恢复默认值的函数
+func extended() (reset func()) {
+	flag.Set("tsf_monitor_extended", "true")
+	return func() { flag.Set("tsf_monitor_extended", "false") }
+}
+
+func testStats() (stats []*Stat) {
+	begin := time.Now()
+	local := &Endpoint{ServiceName: "consumer", InterfaceName: "/hello", Path: "/hello", Method: "GET"}
+	remote := &Endpoint{ServiceName: "provider", InterfaceName: "/echo", Path: "/echo", Method: "POST"}
+	codes := []int{200, 200, 400, 499, 500, 503, 504, StatusBreakerOpen, 302}
+	for i := 0; i < 100; i++ {
+		var r *Endpoint
+		kind := KindServer
+		if i%2 == 0 {
+			r = remote
+			kind = KindClient
+		}
+		stats = append(stats, &Stat{
+			Begin:      begin,
+			End:        begin.Add(time.Duration(i*25) * time.Millisecond),
+			Category:   CategoryMS,
+			Kind:       kind,
+			Local:      local,
+			Remote:     r,
+			StatusCode: codes[i%len(codes)],
+		})
+	}
+	return
+}
+
+func TestAggregate(t *testing.T) {
+	defer extended()()
+	m := newMonitor()
+	for _, s := range testStats() {
+		m.Record(s)
+	}
+	now := time.Now().Unix()
+	aggs := m.swap()
+	assert.Equal(t, 2, len(aggs))
+	for _, agg := range aggs {
+		metric := agg.metric(now)
+		d := metric.Invocation.Duration
+		assert.Equal(t, int64(50), metric.Invocation.SumAmount)
+		assert.True(t, d.P50 > 0 && d.P50 <= d.P90 && d.P90 <= d.P99 && d.P99 <= d.Max)
+		// 客户端是i为偶数的请求，最大耗时2450ms，服务端最大2475ms
+		if metric.Kind == KindClient {
+			assert.Equal(t, 2450.0, d.Max)
+			assert.Equal(t, int64(9), d.RangeInf)
+		} else {
+			assert.Equal(t, 2475.0, d.Max)
+			assert.Equal(t, int64(10), d.RangeInf)
+		}
+	}
+	assert.Equal(t, 0, len(m.swap()))
+}
+
+// aggregateGolden 是goldenStats开启扩展字段后聚合的上报内容，按kind排序
+var aggregateGolden = []string{`{
+	"category": "MS",
+	"kind": "CLIENT",
+	"timestamp": 1600000020,
+	"period": 60,
+	"local": {"service": "consumer", "interface": "/hello", "method": "GET", "path": "/hello"},
+	"remote": {"service": "provider", "interface": "/echo", "method": "POST", "path": "/echo"},
+	"invocation": {
+		"sum_amount": 5,
+		"status_code": [
+			{"code": "200", "amount": 2},
+			{"code": "404", "amount": 1},
+			{"code": "500", "amount": 1},
+			{"code": "504", "amount": 1}
+		],
+		"status_serial": {
+			"informational": 0,
+			"successful": 2,
+			"redirection": 0,
+			"client_error": 1,
+			"server_error": 1,
+			"connect_error": 0,
+			"timeout_error": 1,
+			"unavailable_error": 0,
+			"other_error": 0
+		},
+		"duration": {
+			"avg_ms": 594,
+			"sum_ms": 2970,
+			"p50_ms": 150,
+			"p90_ms": 250,
+			"p99_ms": 250,
+			"max_ms": 2500,
+			"range_50_ms": 1,
+			"range_50_100_ms": 1,
+			"range_100_200_ms": 1,
+			"range_200_300_ms": 1,
+			"range_2000_ms": 1
+		}
+	}
+}`, `{
+	"category": "MS",
+	"kind": "SERVER",
+	"timestamp": 1600000020,
+	"period": 60,
+	"local": {"service": "consumer", "interface": "/hello", "method": "GET", "path": "/hello"},
+	"invocation": {
+		"sum_amount": 4,
+		"status_code": [
+			{"code": "0", "amount": 1},
+			{"code": "14", "amount": 1},
+			{"code": "200", "amount": 1},
+			{"code": "600", "amount": 1}
+		],
+		"status_serial": {
+			"informational": 0,
+			"successful": 2,
+			"redirection": 0,
+			"client_error": 0,
+			"server_error": 0,
+			"connect_error": 0,
+			"timeout_error": 0,
+			"unavailable_error": 1,
+			"other_error": 0,
+			"circuit_breaker_error": 1
+		},
+		"duration": {
+			"avg_ms": 837.5,
+			"sum_ms": 3350,
+			"p50_ms": 100,
+			"p90_ms": 1200,
+			"p99_ms": 1200,
+			"max_ms": 2000,
+			"range_50_ms": 1,
+			"range_50_100_ms": 1,
+			"range_800_1200_ms": 1,
+			"range_1600_2000_ms": 1
+		}
+	}
+}`}
+
+func goldenStats() (stats []*Stat) {
+	begin := time.Unix(1600000000, 0)
+	local := &Endpoint{ServiceName: "consumer", InterfaceName: "/hello", Path: "/hello", Method: "GET"}
+	remote := &Endpoint{ServiceName: "provider", InterfaceName: "/echo", Path: "/echo", Method: "POST"}
+	client := []struct {
+		ms   int
+		code int
+	}{{10, 200}, {60, 200}, {150, 404}, {250, 500}, {2500, 504}}
+	for _, c := range client {
+		stats = append(stats, &Stat{
+			Begin:      begin,
+			End:        begin.Add(time.Duration(c.ms) * time.Millisecond),
+			Category:   CategoryMS,
+			Kind:       KindClient,
+			Local:      local,
+			Remote:     remote,
+			StatusCode: c.code,
+		})
+	}
+	// 服务端包括grpc的状态码0和14(Unavailable)
+	server := []struct {
+		ms   int
+		code int
+	}{{50, 200}, {100, 0}, {2000, 14}, {1200, StatusBreakerOpen}}
+	for _, c := range server {
+		stats = append(stats, &Stat{
+			Begin:      begin,
+			End:        begin.Add(time.Duration(c.ms) * time.Millisecond),
+			Category:   CategoryMS,
+			Kind:       KindServer,
+			Local:      local,
+			StatusCode: c.code,
+		})
+	}
+	return
+}
+
+func TestAggregateGolden(t *testing.T) {
+	defer extended()()
+	m := newMonitor()
+	for _, s := range goldenStats() {
+		m.Record(s)
+	}
+	var metrics []MetricItem
+	for _, agg := range m.swap() {
+		metrics = append(metrics, agg.metric(1600000030))
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Kind < metrics[j].Kind
+	})
+	if !assert.Equal(t, len(aggregateGolden), len(metrics)) {
+		return
+	}
+	for i, metric := range metrics {
+		// 分位值是sketch的近似值，相对误差不超过1%，对比前替换为golden中的精确值
+		var golden MetricItem
+		assert.Nil(t, json.Unmarshal([]byte(aggregateGolden[i]), &golden))
+		d, g := &metric.Invocation.Duration, golden.Invocation.Duration
+		assert.InEpsilon(t, g.P50, d.P50, sketchAccuracy)
+		assert.InEpsilon(t, g.P90, d.P90, sketchAccuracy)
+		assert.InEpsilon(t, g.P99, d.P99, sketchAccuracy)
+		d.P50, d.P90, d.P99 = g.P50, g.P90, g.P99
+
+		content, err := json.Marshal(metric)
+		assert.Nil(t, err)
+		assert.JSONEq(t, aggregateGolden[i], string(content))
+	}
+}
+
+// compatibleGolden 是默认配置下goldenStats聚合的上报内容，和原有的TSF调用日志格式逐字节一致，
+// 熔断拒绝计入other_error
+var compatibleGolden = []string{
+	`{"category":"MS","kind":"CLIENT","timestamp":1600000020,"period":60,` +
+		`"local":{"service":"consumer","interface":"/hello","method":"GET","path":"/hello"},` +
+		`"remote":{"service":"provider","interface":"/echo","method":"POST","path":"/echo"},` +
+		`"invocation":{"sum_amount":5,"status_code":[{"code":"200","amount":2},{"code":"404","amount":1},{"code":"500","amount":1},{"code":"504","amount":1}],` +
+		`"status_serial":{"informational":0,"successful":2,"redirection":0,"client_error":1,"server_error":1,"connect_error":0,"timeout_error":1,"unavailable_error":0,"other_error":0},` +
+		`"duration":{"avg_ms":594,"sum_ms":2970,"range_50_ms":1,"range_50_100_ms":1,"range_100_200_ms":1,"range_200_300_ms":1,"range_2000_ms":1}}}`,
+	`{"category":"MS","kind":"SERVER","timestamp":1600000020,"period":60,` +
+		`"local":{"service":"consumer","interface":"/hello","method":"GET","path":"/hello"},` +
+		`"invocation":{"sum_amount":4,"status_code":[{"code":"0","amount":1},{"code":"14","amount":1},{"code":"200","amount":1},{"code":"600","amount":1}],` +
+		`"status_serial":{"informational":0,"successful":2,"redirection":0,"client_error":0,"server_error":0,"connect_error":0,"timeout_error":0,"unavailable_error":1,"other_error":1},` +
+		`"duration":{"avg_ms":837.5,"sum_ms":3350,"range_50_ms":1,"range_50_100_ms":1,"range_800_1200_ms":1,"range_1600_2000_ms":1}}}`,
+}
+
+func TestAggregateCompatible(t *testing.T) {
+	m := newMonitor()
+	for _, s := range goldenStats() {
+		m.Record(s)
+	}
+	var contents []string
+	for _, agg := range m.swap() {
+		content, err := json.Marshal(agg.metric(1600000030))
+		assert.Nil(t, err)
+		contents = append(contents, string(content))
+	}
+	// CLIENT排在SERVER之前
+	sort.Strings(contents)
+	assert.Equal(t, compatibleGolden, contents)
+}
+
+func TestSketch(t *testing.T) {
+	a := newSketch()
+	for i := 1; i <= 10000; i++ {
+		a.add(float64(i) / 10)
+	}
+	a.add(0)
+	assert.Equal(t, int64(10001), a.count)
+	assert.Equal(t, 1000.0, a.max)
+	for q, want := range map[float64]float64{0.5: 500, 0.9: 900, 0.99: 990} {
+		assert.InEpsilon(t, want, a.quantile(q), 0.011, "p%v", q*100)
+	}
+	assert.Equal(t, 0.0, a.quantile(0))
+	assert.Equal(t, 1000.0, a.quantile(1))
+	assert.Equal(t, 0.0, newSketch().quantile(0.5))
+}
+
+func TestStatusSerial(t *testing.T) {
+	var s StatusSerial
+	for _, code := range []int{100, 200, 204, 302, 400, 404, 499, 408, 504, 429, 503, 502, 500, 501, StatusBreakerOpen, 700} {
+		s.add(code, 1)
+	}
+	// grpc codes
+	for _, code := range []int{0, 3, 4, 14, 13} {
+		s.add(code, 1)
+	}
+	assert.Equal(t, StatusSerial{
+		Informational: 1,
+		Successful:    3,
+		Redirection:   1,
+		ClientErr:     4,
+		ServerErr:     3,
+		ConnErr:       1,
+		Timeout:       3,
+		Unavailable:   3,
+		OtherErr:      1,
+		BreakerOpen:   1,
+	}, s)
+}
+
+func BenchmarkRecord(b *testing.B) {
+	m := newMonitor()
+	stats := testStats()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			s := *stats[i%len(stats)]
+			m.Record(&s)
+			i++
+		}
+	})
+}
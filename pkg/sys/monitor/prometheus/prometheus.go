@@ -0,0 +1,73 @@
+// Package prometheus exports the request stats of tsf-go as prometheus metrics.
+package prometheus
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tencentyun/tsf-go/pkg/sys/monitor"
+)
+
+var _ monitor.Sink = &Sink{}
+
+var labels = []string{"kind", "local_service", "local_interface", "remote_service", "remote_interface", "method", "code"}
+
+// Sink records the request count and duration histogram of every request,
+// labeled by local and remote service, interface, method and status code.
+type Sink struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New new a prometheus sink with its own registry.
+func New() *Sink {
+	s := &Sink{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tsf",
+			Name:      "requests_total",
+			Help:      "The total number of requests.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "tsf",
+			Name:      "request_duration_seconds",
+			Help:      "The request duration in seconds.",
+			// 与TSF调用日志的耗时分桶保持一致
+			Buckets: []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.8, 1.2, 1.6, 2},
+		}, labels),
+	}
+	s.registry.MustRegister(s.requests, s.duration)
+	return s
+}
+
+func (s *Sink) Record(stat *monitor.Stat) {
+	values := []string{stat.Kind, "", "", "", "", "", strconv.Itoa(stat.StatusCode)}
+	if stat.Local != nil {
+		values[1] = stat.Local.ServiceName
+		values[2] = stat.Local.InterfaceName
+		values[5] = stat.Local.Method
+	}
+	if stat.Remote != nil {
+		values[3] = stat.Remote.ServiceName
+		values[4] = stat.Remote.InterfaceName
+		// 客户端使用被调接口的method
+		if stat.Remote.Method != "" {
+			values[5] = stat.Remote.Method
+		}
+	}
+	s.requests.WithLabelValues(values...).Inc()
+	s.duration.WithLabelValues(values...).Observe(stat.End.Sub(stat.Begin).Seconds())
+}
+
+// Handler returns the http handler of metrics, it can be mounted on any http server.
+func (s *Sink) Handler() http.Handler {
+	return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+}
+
+// Registry returns the registry of metrics, the custom metrics can also be registered to it.
+func (s *Sink) Registry() *prometheus.Registry {
+	return s.registry
+}
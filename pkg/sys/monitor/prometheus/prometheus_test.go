@@ -0,0 +1,31 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/sys/monitor"
+)
+
+func TestSink(t *testing.T) {
+	s := New()
+	begin := time.Now()
+	local := &monitor.Endpoint{ServiceName: "consumer", InterfaceName: "/hello", Method: "GET"}
+	remote := &monitor.Endpoint{ServiceName: "provider", InterfaceName: "/echo", Method: "POST"}
+	s.Record(&monitor.Stat{Begin: begin, End: begin.Add(30 * time.Millisecond), Kind: monitor.KindServer, Local: local, StatusCode: 200})
+	s.Record(&monitor.Stat{Begin: begin, End: begin.Add(150 * time.Millisecond), Kind: monitor.KindClient, Local: local, Remote: remote, StatusCode: 503})
+	s.Record(&monitor.Stat{Begin: begin, End: begin.Add(3 * time.Second), Kind: monitor.KindClient, Local: local, Remote: remote, StatusCode: 503})
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(w.Body)
+	assert.Nil(t, err)
+	content := string(body)
+	assert.Contains(t, content, `tsf_requests_total{code="200",kind="SERVER",local_interface="/hello",local_service="consumer",method="GET",remote_interface="",remote_service=""} 1`)
+	assert.Contains(t, content, `tsf_requests_total{code="503",kind="CLIENT",local_interface="/hello",local_service="consumer",method="POST",remote_interface="/echo",remote_service="provider"} 2`)
+	assert.Contains(t, content, `tsf_request_duration_seconds_bucket{code="503",kind="CLIENT",local_interface="/hello",local_service="consumer",method="POST",remote_interface="/echo",remote_service="provider",le="0.2"} 1`)
+	assert.Contains(t, content, `tsf_request_duration_seconds_bucket{code="503",kind="CLIENT",local_interface="/hello",local_service="consumer",method="POST",remote_interface="/echo",remote_service="provider",le="+Inf"} 2`)
+}
@@ -0,0 +1,32 @@
+package monitor
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var _ Sink = &Monitor{}
+
+// Sink receives the stat of every finished request,
+// the default sink is the Monitor which writes the TSF invocation logs.
+type Sink interface {
+	Record(s *Stat)
+}
+
+var sinkLock sync.Mutex
+var sinks atomic.Value
+
+// AddSink adds a sink besides the TSF invocation logs, e.g. prometheus.
+func AddSink(s Sink) {
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+	old := sinks.Load().([]Sink)
+	ss := make([]Sink, 0, len(old)+1)
+	ss = append(ss, old...)
+	ss = append(ss, s)
+	sinks.Store(ss)
+}
+
+func getSinks() []Sink {
+	return sinks.Load().([]Sink)
+}
@@ -0,0 +1,69 @@
+package monitor
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// 相对误差1%
+	sketchAccuracy = 0.01
+	// 小于1us的耗时都记在零值桶中
+	sketchMinValue = 0.001
+)
+
+var (
+	sketchGamma    = (1 + sketchAccuracy) / (1 - sketchAccuracy)
+	sketchLogGamma = math.Log(sketchGamma)
+)
+
+// sketch 是按对数分桶的耗时分布(DDSketch)，单位ms，分位值的相对误差不超过1%
+// 同一个key只会落在一个分片上，上报时不需要合并
+type sketch struct {
+	bins  map[int]int64
+	zero  int64
+	count int64
+	max   float64
+}
+
+func newSketch() *sketch {
+	return &sketch{bins: make(map[int]int64)}
+}
+
+func (s *sketch) add(v float64) {
+	s.count++
+	if v > s.max {
+		s.max = v
+	}
+	if v < sketchMinValue {
+		s.zero++
+		return
+	}
+	s.bins[int(math.Ceil(math.Log(v)/sketchLogGamma))]++
+}
+
+// quantile returns the value at quantile q, e.g. 0.99
+func (s *sketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := int64(q * float64(s.count-1))
+	if rank < s.zero {
+		return 0
+	}
+	keys := make([]int, 0, len(s.bins))
+	for i := range s.bins {
+		keys = append(keys, i)
+	}
+	sort.Ints(keys)
+	n := s.zero
+	for _, i := range keys {
+		n += s.bins[i]
+		if n > rank {
+			// 取桶的中间值，保证相对误差
+			v := 2 * math.Pow(sketchGamma, float64(i)) / (sketchGamma + 1)
+			return math.Min(v, s.max)
+		}
+	}
+	return s.max
+}
@@ -7,6 +7,10 @@ const (
 
 	KindClient = "CLIENT"
 	KindServer = "SERVER"
+
+	// StatusBreakerOpen is the status code of the calls rejected by circuit breaker,
+	// it is counted separately from the unavailable errors.
+	StatusBreakerOpen = 600
 )
 
 type Stat struct {
@@ -39,7 +43,9 @@ func NewStat(category string, kind string, local *Endpoint, remote *Endpoint) *S
 func (s *Stat) Record(statusCode int) {
 	s.End = time.Now()
 	s.StatusCode = statusCode
-	monitor.saveStat(s)
+	for _, sink := range getSinks() {
+		sink.Record(s)
+	}
 }
 
 func (s *Stat) HashCode() string {
@@ -0,0 +1,76 @@
+package tsf
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/config/consul"
+	"github.com/tencentyun/tsf-go/pkg/naming"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+	"github.com/tencentyun/tsf-go/ratelimit"
+	"github.com/tencentyun/tsf-go/ratelimit/bbr"
+)
+
+// WithLoadShedding drops the excess requests with 503 when the server is overloaded,
+// the requests with low priority(user metadata bbr.PriorityKey) are dropped first.
+func WithLoadShedding(cfg *bbr.Config) ServerOption {
+	return func(o *serverOpionts) {
+		o.shedding = cfg
+		if o.shedding == nil {
+			o.shedding = &bbr.Config{}
+		}
+	}
+}
+
+func sheddingMiddleware(cfg *bbr.Config) middleware.Middleware {
+	limiter := bbr.New(cfg)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (resp interface{}, err error) {
+			done, err := limiter.Allow(ctx)
+			if err != nil {
+				return
+			}
+			defer done()
+			return handler(ctx, req)
+		}
+	}
+}
+
+// WithRateLimit limits the requests by the rate limit rules published by tsf,
+// the rules are subscribed from source, consul.DefaultConsul() is used if source is nil.
+// The requests exceeded the quota are rejected with 429.
+func WithRateLimit(source config.Source) ServerOption {
+	return func(o *serverOpionts) {
+		o.ratelimit = true
+		o.ratelimitSource = source
+	}
+}
+
+func ratelimitMiddleware(source config.Source) middleware.Middleware {
+	var limiter *ratelimit.Limiter
+	var once sync.Once
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (resp interface{}, err error) {
+			once.Do(func() {
+				k, _ := kratos.FromContext(ctx)
+				serviceName := k.Name()
+				if source == nil {
+					source = consul.DefaultConsul()
+				}
+				builder := &ratelimit.Builder{}
+				limiter = builder.Build(source, naming.NewService(env.NamespaceID(), serviceName))
+				onShutdown(limiter.Close)
+			})
+			// 限流
+			err = limiter.Allow(ctx)
+			if err != nil {
+				return
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package bbr
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	"github.com/tencentyun/tsf-go/pkg/metric"
+	"github.com/tencentyun/tsf-go/pkg/sys/cpu"
+)
+
+const (
+	// ReasonOverload is the error reason returned when a request is shed.
+	ReasonOverload = "overload"
+
+	// PriorityKey is the user metadata key which carries the priority of a request.
+	// valid values: high, normal, low
+	PriorityKey = "priority"
+
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// 不同优先级请求允许的并发数占估算容量的比例
+// 过载时低优先级请求最先被丢弃
+var priorityRatio = map[string]float64{
+	PriorityHigh:   1.25,
+	PriorityNormal: 1,
+	PriorityLow:    0.8,
+}
+
+// Config bbr limiter config.
+type Config struct {
+	// 统计时间窗口
+	// 默认值 10s
+	Window time.Duration
+	// 默认值 100
+	Bucket int
+	// cpu使用率(千分比)超过该值后开始丢弃请求
+	// 默认值 800
+	CPUThreshold uint64
+	// 触发丢弃后的冷却时间,期间即使cpu回落也按估算容量继续丢弃
+	// 默认值 1s
+	CoolDown time.Duration
+}
+
+func (conf *Config) fix() {
+	if conf.Window == 0 {
+		conf.Window = 10 * time.Second
+	}
+	if conf.Bucket == 0 {
+		conf.Bucket = 100
+	}
+	if conf.CPUThreshold == 0 {
+		conf.CPUThreshold = 800
+	}
+	if conf.CoolDown == 0 {
+		conf.CoolDown = time.Second
+	}
+}
+
+// Stat is the statistics of a bbr limiter.
+type Stat struct {
+	CPU         uint64
+	InFlight    int64
+	MaxInFlight int64
+	MinRT       int64
+	MaxPass     int64
+}
+
+// BBR estimates the capacity of the server by max pass and min rt
+// in the window (Little's Law), the requests exceed the capacity are
+// shed when the cpu is overloaded.
+type BBR struct {
+	cpu      func() uint64
+	passStat metric.RollingCounter
+	rtStat   metric.RollingCounter
+	inFlight int64
+
+	bucketDuration  time.Duration
+	bucketPerSecond int64
+	conf            *Config
+
+	// unix nano of last drop
+	prevDrop int64
+}
+
+// New creates a bbr limiter.
+func New(conf *Config) *BBR {
+	if conf == nil {
+		conf = &Config{}
+	}
+	conf.fix()
+	bucketDuration := conf.Window / time.Duration(conf.Bucket)
+	opts := metric.RollingCounterOpts{
+		Size:           conf.Bucket,
+		BucketDuration: bucketDuration,
+	}
+	return &BBR{
+		cpu:             cpu.Usage,
+		passStat:        metric.NewRollingCounter(opts),
+		rtStat:          metric.NewRollingCounter(opts),
+		bucketDuration:  bucketDuration,
+		bucketPerSecond: int64(time.Second / bucketDuration),
+		conf:            conf,
+	}
+}
+
+func (l *BBR) maxPass() int64 {
+	val := int64(l.passStat.Reduce(func(iterator metric.Iterator) float64 {
+		var result = 1.0
+		for iterator.Next() {
+			bucket := iterator.Bucket()
+			count := 0.0
+			for _, p := range bucket.Points {
+				count += p
+			}
+			result = math.Max(result, count)
+		}
+		return result
+	}))
+	return val
+}
+
+func (l *BBR) minRT() int64 {
+	val := l.rtStat.Reduce(func(iterator metric.Iterator) float64 {
+		var result = math.MaxFloat64
+		for iterator.Next() {
+			bucket := iterator.Bucket()
+			if len(bucket.Points) == 0 {
+				continue
+			}
+			total := 0.0
+			for _, p := range bucket.Points {
+				total += p
+			}
+			result = math.Min(result, total/float64(bucket.Count))
+		}
+		return result
+	})
+	if val == math.MaxFloat64 {
+		return 1
+	}
+	return int64(math.Ceil(val))
+}
+
+func (l *BBR) maxInFlight() int64 {
+	return int64(math.Floor(float64(l.maxPass()*l.minRT()*l.bucketPerSecond)/1000.0 + 0.5))
+}
+
+func (l *BBR) shouldDrop(ratio float64) bool {
+	inFlight := atomic.LoadInt64(&l.inFlight)
+	overflow := inFlight > 1 && float64(inFlight) > float64(l.maxInFlight())*ratio
+	if l.cpu() < l.conf.CPUThreshold {
+		prevDrop := atomic.LoadInt64(&l.prevDrop)
+		if prevDrop == 0 || time.Duration(time.Now().UnixNano()-prevDrop) > l.conf.CoolDown {
+			return false
+		}
+		return overflow
+	}
+	if overflow {
+		atomic.StoreInt64(&l.prevDrop, time.Now().UnixNano())
+	}
+	return overflow
+}
+
+// Stat returns the current statistics of the limiter.
+func (l *BBR) Stat() Stat {
+	return Stat{
+		CPU:         l.cpu(),
+		InFlight:    atomic.LoadInt64(&l.inFlight),
+		MaxInFlight: l.maxInFlight(),
+		MinRT:       l.minRT(),
+		MaxPass:     l.maxPass(),
+	}
+}
+
+// Allow checks whether the request in ctx is allowed to pass,
+// returns a 503 error if the request is shed, otherwise the done func
+// must be called when the request is finished.
+func (l *BBR) Allow(ctx context.Context) (func(), error) {
+	priority := meta.User(ctx, PriorityKey)
+	ratio, ok := priorityRatio[priority]
+	if !ok {
+		ratio = priorityRatio[PriorityNormal]
+	}
+	if l.shouldDrop(ratio) {
+		log.DefaultLog.WithContext(ctx).Debugw("msg", "BBR.Allow server overload,drop request!", "priority", priority, "stat", l.Stat())
+		return nil, errors.New(503, ReasonOverload, "server is overloaded,request is shed")
+	}
+	atomic.AddInt64(&l.inFlight, 1)
+	start := time.Now()
+	return func() {
+		l.rtStat.Add(time.Since(start).Milliseconds())
+		atomic.AddInt64(&l.inFlight, -1)
+		l.passStat.Add(1)
+	}, nil
+}
@@ -0,0 +1,82 @@
+package bbr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+)
+
+func newLimiter(usage uint64) *BBR {
+	l := New(&Config{Window: time.Second, Bucket: 10})
+	l.cpu = func() uint64 { return usage }
+	return l
+}
+
+func TestBBRAllow(t *testing.T) {
+	l := newLimiter(0)
+	var dones []func()
+	for i := 0; i < 100; i++ {
+		done, err := l.Allow(context.Background())
+		assert.Nil(t, err)
+		dones = append(dones, done)
+	}
+	for _, done := range dones {
+		done()
+	}
+	assert.Equal(t, int64(0), l.Stat().InFlight)
+}
+
+func TestBBRShed(t *testing.T) {
+	l := newLimiter(900)
+	// 10 requests per bucket(100ms) with rt 1ms, max inflight is 0,
+	// the requests are dropped once inflight exceeds 1
+	for i := 0; i < 10; i++ {
+		l.passStat.Add(1)
+		l.rtStat.Add(1)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := l.Allow(context.Background())
+		assert.Nil(t, err)
+	}
+	_, err := l.Allow(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, 503, errors.Code(err))
+	assert.Equal(t, ReasonOverload, errors.Reason(err))
+
+	// cpu recovered but still in cool down
+	l.cpu = func() uint64 { return 0 }
+	_, err = l.Allow(context.Background())
+	assert.NotNil(t, err)
+	l.prevDrop = time.Now().Add(-2 * time.Second).UnixNano()
+	_, err = l.Allow(context.Background())
+	assert.Nil(t, err)
+}
+
+func TestBBRPriority(t *testing.T) {
+	l := newLimiter(900)
+	for i := 0; i < 100; i++ {
+		l.passStat.Add(1)
+	}
+	l.rtStat.Add(10)
+	// 100 requests per bucket(100ms) with rt 10ms, max inflight is 10
+	low := meta.WithUser(context.Background(), meta.UserPair{Key: PriorityKey, Value: PriorityLow})
+	high := meta.WithUser(context.Background(), meta.UserPair{Key: PriorityKey, Value: PriorityHigh})
+	for i := 0; i < 9; i++ {
+		_, err := l.Allow(context.Background())
+		assert.Nil(t, err)
+	}
+	_, err := l.Allow(low)
+	assert.NotNil(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = l.Allow(context.Background())
+		assert.Nil(t, err)
+	}
+	_, err = l.Allow(context.Background())
+	assert.NotNil(t, err)
+	_, err = l.Allow(high)
+	assert.Nil(t, err)
+}
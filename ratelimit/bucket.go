@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket which refills quota tokens every duration.
+type bucket struct {
+	mu       sync.Mutex
+	capacity float64
+	// tokens refilled per nanosecond
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(quota int64, duration time.Duration) *bucket {
+	return &bucket{
+		capacity: float64(quota),
+		rate:     float64(quota) / float64(duration),
+		tokens:   float64(quota),
+		last:     time.Now(),
+	}
+}
+
+// take tries to consume one token, returns false if the bucket is empty.
+func (b *bucket) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(elapsed) * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund gives back a token taken by take.
+func (b *bucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
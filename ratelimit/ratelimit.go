@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/config"
+	"github.com/tencentyun/tsf-go/pkg/naming"
+)
+
+// ReasonRateLimited is the error reason returned when a request is rejected by limiter.
+const ReasonRateLimited = "rate_limited"
+
+type Builder struct {
+}
+
+func (b *Builder) Build(cfg config.Source, svc naming.Service) *Limiter {
+	watcher := cfg.Subscribe(limitKey(svc))
+	l := &Limiter{watcher: watcher, svc: svc, buckets: map[string]*bucket{}}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	go l.refreshRule()
+	return l
+}
+
+// Limiter limits the requests of a service by the rate limit rules published by tsf.
+type Limiter struct {
+	watcher config.Watcher
+	svc     naming.Service
+	rules   []Rule
+	buckets map[string]*bucket
+
+	mu sync.RWMutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Allow reports whether the request in ctx is allowed to pass,
+// returns a 429 error if any hit rule has exhausted its quota.
+func (l *Limiter) Allow(ctx context.Context) error {
+	l.mu.RLock()
+	rules := l.rules
+	buckets := l.buckets
+	l.mu.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var taken []*bucket
+	for _, rule := range rules {
+		if !rule.tagRule.Hit(ctx) {
+			continue
+		}
+		b, ok := buckets[rule.ID]
+		if !ok {
+			continue
+		}
+		if !b.take(now) {
+			// 请求被拒绝，归还之前命中的规则已扣除的令牌
+			for _, t := range taken {
+				t.refund()
+			}
+			log.DefaultLog.WithContext(ctx).Debugw("msg", "Limiter.Allow hit rule,quota exhausted!", "rule", rule.ID, "quota", rule.TotalQuota, "duration", rule.Duration)
+			return errors.New(429, ReasonRateLimited, fmt.Sprintf("rate limit rule %s exceeded", rule.ID))
+		}
+		taken = append(taken, b)
+	}
+	return nil
+}
+
+// Close stops watching the rate limit rules.
+func (l *Limiter) Close() {
+	l.cancel()
+	l.watcher.Close()
+}
+
+func (l *Limiter) refreshRule() {
+	key := limitKey(l.svc)
+	for {
+		specs, err := l.watcher.Watch(l.ctx)
+		if err != nil {
+			if errors.IsGatewayTimeout(err) || errors.IsClientClosed(err) {
+				log.DefaultLog.Errorw("msg", "watch ratelimit config deadline or clsoe!exit now!", "err", err)
+				return
+			}
+			log.DefaultLog.Errorw("msg", "watch ratelimit config failed!", "err", err)
+			continue
+		}
+		var limitConfigs []Config
+		for _, spec := range specs {
+			if spec.Key != key {
+				err = fmt.Errorf("found invalid ratelimit config key!")
+				log.DefaultLog.Errorw("msg", "found invalid ratelimit config key!", "key", spec.Key, "expect", key)
+				continue
+			}
+			err = spec.Data.Unmarshal(&limitConfigs)
+			if err != nil {
+				log.DefaultLog.Errorw("msg", "unmarshal ratelimit config failed!", "err", err, "raw", string(spec.Data.Raw()))
+				continue
+			}
+		}
+		if len(limitConfigs) == 0 && err != nil {
+			log.DefaultLog.Error("get ratelimit config failed,not override old data!")
+			continue
+		}
+		var rules []Rule
+		if len(limitConfigs) > 0 {
+			for _, rule := range limitConfigs[0].Rules {
+				if !rule.valid() {
+					log.DefaultLog.Errorw("msg", "found invalid ratelimit rule,ignore it!", "rule", rule.ID, "duration", rule.Duration, "quota", rule.TotalQuota)
+					continue
+				}
+				rule.genTagRules()
+				rules = append(rules, rule)
+			}
+		}
+		log.DefaultLog.Infof("[ratelimit] found new ratelimit rules,replace now!rules: %v", rules)
+		l.update(rules)
+	}
+}
+
+func (l *Limiter) update(rules []Rule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buckets := make(map[string]*bucket, len(rules))
+	for _, rule := range rules {
+		// 规则的配额没有变化时沿用原有的令牌桶，避免配置推送导致配额被重置
+		if b, ok := l.buckets[rule.ID]; ok && l.sameQuota(rule) {
+			buckets[rule.ID] = b
+			continue
+		}
+		buckets[rule.ID] = newBucket(rule.TotalQuota, time.Duration(rule.Duration)*time.Second)
+	}
+	l.rules = rules
+	l.buckets = buckets
+}
+
+func (l *Limiter) sameQuota(rule Rule) bool {
+	for _, old := range l.rules {
+		if old.ID == rule.ID {
+			return old.Duration == rule.Duration && old.TotalQuota == rule.TotalQuota
+		}
+	}
+	return false
+}
+
+func limitKey(svc naming.Service) string {
+	return fmt.Sprintf("ratelimit/%s/%s/data", svc.Namespace, svc.Name)
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+)
+
+func TestBucket(t *testing.T) {
+	b := newBucket(10, time.Second)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		assert.True(t, b.take(now))
+	}
+	assert.False(t, b.take(now))
+	// 100ms refills one token
+	assert.True(t, b.take(now.Add(time.Millisecond*100)))
+	assert.False(t, b.take(now.Add(time.Millisecond*100)))
+	// never refill more than capacity
+	for i := 0; i < 10; i++ {
+		assert.True(t, b.take(now.Add(time.Hour)))
+	}
+	assert.False(t, b.take(now.Add(time.Hour)))
+}
+
+func TestLimiterAllow(t *testing.T) {
+	l := &Limiter{buckets: map[string]*bucket{}}
+	rule := Rule{
+		ID:         "limit-rule-1",
+		Duration:   60,
+		TotalQuota: 2,
+		Conditions: []Tag{{Type: "S", Field: "source.service.name", Operator: "EQUAL", Value: "consumer"}},
+	}
+	rule.genTagRules()
+	l.update([]Rule{rule})
+
+	hit := meta.WithSys(context.Background(), meta.SysPair{Key: meta.SourceKey(meta.ServiceName), Value: "consumer"})
+	miss := meta.WithSys(context.Background(), meta.SysPair{Key: meta.SourceKey(meta.ServiceName), Value: "other"})
+	assert.Nil(t, l.Allow(hit))
+	assert.Nil(t, l.Allow(hit))
+	err := l.Allow(hit)
+	assert.NotNil(t, err)
+	assert.Equal(t, 429, errors.Code(err))
+	assert.Equal(t, ReasonRateLimited, errors.Reason(err))
+	assert.Nil(t, l.Allow(miss))
+
+	// reload with the same quota keeps the exhausted bucket
+	l.update([]Rule{rule})
+	assert.NotNil(t, l.Allow(hit))
+	// quota changed, bucket is rebuilt
+	rule.TotalQuota = 3
+	l.update([]Rule{rule})
+	assert.Nil(t, l.Allow(hit))
+}
+
+func TestLimiterRefund(t *testing.T) {
+	l := &Limiter{buckets: map[string]*bucket{}}
+	wide := Rule{
+		ID:         "limit-rule-wide",
+		Duration:   60,
+		TotalQuota: 3,
+	}
+	narrow := Rule{
+		ID:         "limit-rule-narrow",
+		Duration:   60,
+		TotalQuota: 1,
+		Conditions: []Tag{{Type: "S", Field: "source.service.name", Operator: "EQUAL", Value: "consumer"}},
+	}
+	wide.genTagRules()
+	narrow.genTagRules()
+	l.update([]Rule{wide, narrow})
+
+	hit := meta.WithSys(context.Background(), meta.SysPair{Key: meta.SourceKey(meta.ServiceName), Value: "consumer"})
+	miss := meta.WithSys(context.Background(), meta.SysPair{Key: meta.SourceKey(meta.ServiceName), Value: "other"})
+	assert.Nil(t, l.Allow(hit))
+	// narrow规则拒绝的请求不消耗wide规则的配额
+	for i := 0; i < 5; i++ {
+		assert.NotNil(t, l.Allow(hit))
+	}
+	assert.Nil(t, l.Allow(miss))
+	assert.Nil(t, l.Allow(miss))
+	assert.NotNil(t, l.Allow(miss))
+}
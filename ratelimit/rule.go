@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"strings"
+
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	"github.com/tencentyun/tsf-go/pkg/sys/tag"
+)
+
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+type Rule struct {
+	ID   string `yaml:"ruleId"`
+	Name string `yaml:"ruleName"`
+	// 限流统计周期，单位秒
+	Duration int64 `yaml:"duration"`
+	// 统计周期内允许通过的请求数
+	TotalQuota int64 `yaml:"totalQuota"`
+	Conditions []Tag `yaml:"conditions"`
+	tagRule    tag.Rule
+}
+
+type Tag struct {
+	ID       string `yaml:"tagId"`
+	Type     string `yaml:"tagType"`
+	Field    string `yaml:"tagField"`
+	Operator string `yaml:"tagOperator"`
+	Value    string `yaml:"tagValue"`
+}
+
+func (rule *Rule) genTagRules() {
+	var tagRule tag.Rule
+	tagRule.Expression = tag.AND
+	tagRule.ID = rule.ID
+	tagRule.Name = rule.Name
+	for _, limitTag := range rule.Conditions {
+		var t tag.Tag
+		if limitTag.Type == "S" && limitTag.Field == "source.namespace.service.name" {
+			values := strings.SplitN(limitTag.Value, "/", 2)
+			if len(values) != 2 {
+				continue
+			}
+			t.Field = meta.SourceKey(meta.Namespace)
+			t.Operator = limitTag.Operator
+			t.Type = tag.TypeSys
+			t.Value = values[0]
+			tagRule.Tags = append(tagRule.Tags, t)
+
+			t.Field = meta.SourceKey(meta.ServiceName)
+			t.Operator = limitTag.Operator
+			t.Type = tag.TypeSys
+			t.Value = values[1]
+			tagRule.Tags = append(tagRule.Tags, t)
+			continue
+		}
+		t.Field = limitTag.Field
+		if strings.HasPrefix(t.Field, meta.PrefixDest) {
+			t.Field = strings.TrimPrefix(t.Field, meta.PrefixDest)
+		}
+		t.Operator = limitTag.Operator
+		if limitTag.Type == "S" {
+			t.Type = tag.TypeSys
+		} else {
+			t.Type = tag.TypeUser
+		}
+		t.Value = limitTag.Value
+		tagRule.Tags = append(tagRule.Tags, t)
+	}
+	rule.tagRule = tagRule
+}
+
+// valid 限流周期或配额非法的规则直接忽略
+func (rule *Rule) valid() bool {
+	return rule.Duration > 0 && rule.TotalQuota > 0
+}
@@ -0,0 +1,178 @@
+package tsf
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/tencentyun/tsf-go/balancer"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/metric"
+	"github.com/tencentyun/tsf-go/pkg/util"
+)
+
+// RetryConfig is client retry config.
+type RetryConfig struct {
+	// 最大重试次数(不包含第一次请求)
+	// 默认值 2
+	Attempts int
+	// 需要重试的错误码
+	// 默认值 503
+	Codes []int
+	// 需要重试的错误原因(errors.Reason)
+	Reasons []string
+	// 重试间隔的退避策略
+	Backoff *util.BackoffConfig
+
+	// 重试预算: 统计窗口内重试请求数不能超过 请求数*BudgetRatio + BudgetMinRetries
+	// 避免服务故障时重试请求放大流量
+	// 默认值 0.1
+	BudgetRatio float64
+	// 默认值 10
+	BudgetMinRetries int64
+	// 默认值 10s
+	BudgetWindow time.Duration
+}
+
+func (conf *RetryConfig) fix() {
+	if conf.Attempts == 0 {
+		conf.Attempts = 2
+	}
+	if len(conf.Codes) == 0 && len(conf.Reasons) == 0 {
+		conf.Codes = []int{503}
+	}
+	if conf.Backoff == nil {
+		conf.Backoff = &util.BackoffConfig{
+			MaxDelay:  time.Second,
+			BaseDelay: 25 * time.Millisecond,
+			Factor:    1.6,
+			Jitter:    0.2,
+		}
+	}
+	if conf.BudgetRatio == 0 {
+		conf.BudgetRatio = 0.1
+	}
+	if conf.BudgetMinRetries == 0 {
+		conf.BudgetMinRetries = 10
+	}
+	if conf.BudgetWindow == 0 {
+		conf.BudgetWindow = 10 * time.Second
+	}
+}
+
+func (conf *RetryConfig) retryable(err error) bool {
+	e := errors.FromError(err)
+	for _, code := range conf.Codes {
+		if int(e.GetCode()) == code {
+			return true
+		}
+	}
+	for _, reason := range conf.Reasons {
+		if e.GetReason() == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRetry retries the failed call on another instance
+func WithRetry(cfg *RetryConfig) ClientOption {
+	return func(o *clientOpionts) {
+		o.retryCfg = cfg
+	}
+}
+
+// retryBudget limits the ratio of retries to requests of a client.
+type retryBudget struct {
+	requests metric.RollingCounter
+	retries  metric.RollingCounter
+	ratio    float64
+	min      int64
+}
+
+func newRetryBudget(conf *RetryConfig) *retryBudget {
+	opts := metric.RollingCounterOpts{
+		Size:           10,
+		BucketDuration: conf.BudgetWindow / 10,
+	}
+	return &retryBudget{
+		requests: metric.NewRollingCounter(opts),
+		retries:  metric.NewRollingCounter(opts),
+		ratio:    conf.BudgetRatio,
+		min:      conf.BudgetMinRetries,
+	}
+}
+
+func (b *retryBudget) request() {
+	b.requests.Add(1)
+}
+
+// withdraw tries to take a retry from the budget.
+func (b *retryBudget) withdraw() bool {
+	if b.retries.Value() >= int64(float64(b.requests.Value())*b.ratio)+b.min {
+		return false
+	}
+	b.retries.Add(1)
+	return true
+}
+
+type retryAttemptKey struct{}
+
+// retryAttempt returns the retry attempt of current call, 0 means the first call.
+func retryAttempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryAttemptKey{}).(int)
+	return attempt
+}
+
+func retryMiddleware(conf *RetryConfig) middleware.Middleware {
+	conf.fix()
+	budget := newRetryBudget(conf)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (reply interface{}, err error) {
+			budget.request()
+			ctx, picked := balancer.NewPickedContext(ctx)
+			for attempt := 0; ; attempt++ {
+				if attempt > 0 {
+					rewindBody(ctx)
+				}
+				reply, err = handler(context.WithValue(ctx, retryAttemptKey{}, attempt), req)
+				if err == nil || attempt >= conf.Attempts || !conf.retryable(err) {
+					return
+				}
+				if !budget.withdraw() {
+					log.DefaultLog.WithContext(ctx).Debugw("msg", "retry budget exhausted,give up retry!", "attempt", attempt, "err", err)
+					return
+				}
+				log.DefaultLog.WithContext(ctx).Debugw("msg", "call failed,retry on another instance!", "attempt", attempt+1, "last", picked.Last(), "err", err)
+				timer := time.NewTimer(conf.Backoff.Backoff(attempt))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+	}
+}
+
+// rewindBody resets the consumed http request body before the next attempt.
+func rewindBody(ctx context.Context) {
+	tr, ok := transport.FromClientContext(ctx)
+	if !ok || tr.Kind() != transport.KindHTTP {
+		return
+	}
+	ht, ok := tr.(*http.Transport)
+	if !ok || ht.Request() == nil || ht.Request().GetBody == nil {
+		return
+	}
+	body, err := ht.Request().GetBody()
+	if err != nil {
+		log.DefaultLog.WithContext(ctx).Errorw("msg", "rewind http request body failed!", "err", err)
+		return
+	}
+	ht.Request().Body = body
+}
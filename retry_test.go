@@ -0,0 +1,171 @@
+package tsf
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	nhttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/balancer"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/util"
+)
+
+// testRetryConfig 去掉退避等待，加快测试
+func testRetryConfig() *RetryConfig {
+	return &RetryConfig{Backoff: &util.BackoffConfig{MaxDelay: time.Millisecond, BaseDelay: time.Millisecond, Factor: 1}}
+}
+
+func TestRetryable(t *testing.T) {
+	conf := &RetryConfig{Codes: []int{503, 504}, Reasons: []string{"RETRY"}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"code", errors.ServiceUnavailable("", ""), true},
+		{"another code", errors.GatewayTimeout("", ""), true},
+		{"reason", errors.BadRequest("RETRY", ""), true},
+		{"not retryable", errors.BadRequest("", ""), false},
+		{"unknown error", context.Canceled, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, conf.retryable(test.err))
+		})
+	}
+
+	// 默认只重试503
+	conf = &RetryConfig{}
+	conf.fix()
+	assert.True(t, conf.retryable(errors.ServiceUnavailable("", "")))
+	assert.False(t, conf.retryable(errors.InternalServer("", "")))
+}
+
+func TestRetryAttempts(t *testing.T) {
+	conf := testRetryConfig()
+	conf.Attempts = 3
+	var attempts []int
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		attempts = append(attempts, retryAttempt(ctx))
+		return nil, errors.ServiceUnavailable("", "")
+	}
+	_, err := retryMiddleware(conf)(handler)(context.Background(), nil)
+	assert.Equal(t, 503, int(errors.Code(err)))
+	assert.Equal(t, []int{0, 1, 2, 3}, attempts)
+
+	// 不可重试的错误直接返回
+	attempts = nil
+	handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+		attempts = append(attempts, retryAttempt(ctx))
+		return nil, errors.BadRequest("", "")
+	}
+	_, err = retryMiddleware(conf)(handler)(context.Background(), nil)
+	assert.Equal(t, 400, int(errors.Code(err)))
+	assert.Equal(t, []int{0}, attempts)
+}
+
+func TestRetryBudget(t *testing.T) {
+	conf := testRetryConfig()
+	conf.Attempts = 5
+	conf.BudgetRatio = 0.5
+	conf.BudgetMinRetries = 2
+	var calls int
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return nil, errors.ServiceUnavailable("", "")
+	}
+	h := retryMiddleware(conf)(handler)
+	// 1个请求的预算是 1*0.5+2 = 2次重试
+	h(context.Background(), nil)
+	assert.Equal(t, 3, calls)
+	// 2个请求的预算是 2*0.5+2 = 3次重试，只剩1次
+	calls = 0
+	h(context.Background(), nil)
+	assert.Equal(t, 2, calls)
+	// 预算耗尽后不再重试
+	calls = 0
+	h(context.Background(), nil)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryAnotherInstance(t *testing.T) {
+	nodes := []naming.Instance{
+		{Host: "127.0.0.1", Port: 8080},
+		{Host: "127.0.0.1", Port: 8081},
+		{Host: "127.0.0.1", Port: 8082},
+	}
+	var picks []string
+	// 模拟负载均衡总是选择第一个没有被选过的实例
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		node := balancer.Exclude(ctx, nodes)[0]
+		balancer.Record(ctx, &node)
+		picks = append(picks, node.Addr())
+		if len(picks) < 3 {
+			return nil, errors.ServiceUnavailable("", "")
+		}
+		return "ok", nil
+	}
+	reply, err := retryMiddleware(testRetryConfig())(handler)(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", reply)
+	assert.Equal(t, []string{nodes[0].Addr(), nodes[1].Addr(), nodes[2].Addr()}, picks)
+}
+
+func TestRetryHTTPBody(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		first := len(bodies) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(nhttp.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer srv.Close()
+
+	// 记录每次重试时请求中的body，新版本net/http自身也会用GetBody重发，这里直接检查rewindBody的结果
+	var attempts []string
+	record := func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, _ := transport.FromClientContext(ctx)
+			r := tr.(*http.Transport).Request()
+			body, _ := ioutil.ReadAll(r.Body)
+			attempts = append(attempts, string(body))
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			return handler(ctx, req)
+		}
+	}
+	cli, err := http.NewClient(context.Background(),
+		http.WithEndpoint(srv.Listener.Addr().String()),
+		http.WithMiddleware(retryMiddleware(testRetryConfig()), record),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer cli.Close()
+	var reply struct {
+		Message string `json:"message"`
+	}
+	err = cli.Invoke(context.Background(), "POST", "/hello", map[string]string{"name": "tsf"}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", reply.Message)
+	// 重试时重新发送完整的请求体
+	assert.Equal(t, []string{`{"name":"tsf"}`, `{"name":"tsf"}`}, attempts)
+	assert.Equal(t, []string{`{"name":"tsf"}`, `{"name":"tsf"}`}, bodies)
+}
@@ -3,6 +3,7 @@ package composite
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/tencentyun/tsf-go/naming"
 	"github.com/tencentyun/tsf-go/route"
@@ -19,8 +20,11 @@ var (
 )
 
 type Composite struct {
-	route route.Router
+	route *router.Router
 	lane  *lane.Lane
+
+	// filters run after lane and route, e.g. instance level circuit breaker
+	filters atomic.Value
 }
 
 func DefaultComposite() *Composite {
@@ -41,9 +45,31 @@ func (c *Composite) Select(ctx context.Context, svc naming.Service, nodes []nami
 	if len(res) == 0 {
 		return res
 	}
-	return c.route.Select(ctx, svc, res)
+	res = c.route.Select(ctx, svc, res)
+	filters, _ := c.filters.Load().([]route.Router)
+	for _, f := range filters {
+		if len(res) == 0 {
+			break
+		}
+		res = f.Select(ctx, svc, res)
+	}
+	return res
+}
+
+// AddFilter appends a filter to the composite router.
+func (c *Composite) AddFilter(f route.Router) {
+	mu.Lock()
+	defer mu.Unlock()
+	filters, _ := c.filters.Load().([]route.Router)
+	c.filters.Store(append(filters[:len(filters):len(filters)], f))
 }
 
 func (c *Composite) Lane() *lane.Lane {
 	return c.lane
 }
+
+// Close stops the router and lane of the composite.
+func (c *Composite) Close() {
+	c.route.Close()
+	c.lane.Close()
+}
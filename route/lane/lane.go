@@ -71,6 +71,13 @@ func New(cfg config.Source) (lane *Lane) {
 	return
 }
 
+// Close stops watching the lane rules and infos.
+func (l *Lane) Close() {
+	l.cancel()
+	l.ruleWatcher.Close()
+	l.laneWathcer.Close()
+}
+
 func (l *Lane) GetLaneID(ctx context.Context) string {
 	l.mu.RLock()
 	rules := l.rules
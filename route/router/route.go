@@ -179,4 +179,5 @@ func (r *Router) refresh() {
 
 func (r *Router) Close() {
 	r.cancel()
+	r.watcher.Close()
 }
@@ -6,12 +6,16 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/tencentyun/tsf-go/fault"
 	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/pkg/config"
 	tsfHttp "github.com/tencentyun/tsf-go/pkg/http"
 	"github.com/tencentyun/tsf-go/pkg/meta"
 	"github.com/tencentyun/tsf-go/pkg/sys/env"
 	"github.com/tencentyun/tsf-go/pkg/util"
+	"github.com/tencentyun/tsf-go/ratelimit/bbr"
 
 	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/metadata"
@@ -24,6 +28,12 @@ import (
 type ServerOption func(*serverOpionts)
 
 type serverOpionts struct {
+	loadReport      bool
+	shedding        *bbr.Config
+	ratelimit       bool
+	ratelimitSource config.Source
+	fault           bool
+	faultSource     config.Source
 }
 
 func startServerContext(ctx context.Context, serviceName string, method string, operation string, addr string) context.Context {
@@ -138,6 +148,8 @@ func serverMiddleware() middleware.Middleware {
 			method, operation := ServerOperation(ctx)
 			ctx = startServerContext(ctx, serviceName, method, operation, localAddr)
 
+			atomic.AddInt64(&inflight, 1)
+			defer atomic.AddInt64(&inflight, -1)
 			resp, err = handler(ctx, req)
 			return
 		}
@@ -146,5 +158,25 @@ func serverMiddleware() middleware.Middleware {
 
 // ServerMiddleware is a grpc server middleware.
 func ServerMiddleware(opts ...ServerOption) middleware.Middleware {
-	return middleware.Chain(mmeta.Server(mmeta.WithPropagatedPrefix("")), serverMiddleware(), tracingServer(), serverMetricsMiddleware(), authMiddleware())
+	var o serverOpionts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := []middleware.Middleware{mmeta.Server(mmeta.WithPropagatedPrefix("")), serverMiddleware()}
+	if o.loadReport {
+		m = append(m, loadReportMiddleware())
+	}
+	m = append(m, tracingServer(), serverMetricsMiddleware())
+	// 过载保护放在tracing和监控之后,被丢弃的请求也会被记录
+	if o.shedding != nil {
+		m = append(m, sheddingMiddleware(o.shedding))
+	}
+	m = append(m, authMiddleware())
+	if o.ratelimit {
+		m = append(m, ratelimitMiddleware(o.ratelimitSource))
+	}
+	if o.fault {
+		m = append(m, faultMiddleware(fault.SideServer, o.faultSource))
+	}
+	return middleware.Chain(m...)
 }
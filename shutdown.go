@@ -0,0 +1,205 @@
+package tsf
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/tencentyun/tsf-go/log"
+	"github.com/tencentyun/tsf-go/naming/consul"
+	"github.com/tencentyun/tsf-go/pkg/sys/monitor"
+	"github.com/tencentyun/tsf-go/route/composite"
+	"github.com/tencentyun/tsf-go/tracing"
+)
+
+const (
+	// 注销实例后等待调用方感知的时间
+	defaultShutdownDelay = 3 * time.Second
+	// 优雅退出的总超时时间
+	defaultShutdownTimeout = 30 * time.Second
+	// 刷新监控和调用链数据的超时时间
+	flushTimeout = 5 * time.Second
+)
+
+var (
+	shutdownOnce sync.Once
+	shutdownErr  error
+
+	closerMu sync.Mutex
+	closers  []func()
+
+	compositeOnce sync.Once
+
+	registrarMu      sync.Mutex
+	defaultRegistrar *shutdownRegistrar
+)
+
+// onShutdown registers a closer which is called in the last step of Shutdown.
+func onShutdown(f func()) {
+	closerMu.Lock()
+	closers = append(closers, f)
+	closerMu.Unlock()
+}
+
+// useDefaultComposite returns the default composite router which is closed on shutdown.
+func useDefaultComposite() *composite.Composite {
+	c := composite.DefaultComposite()
+	compositeOnce.Do(func() {
+		onShutdown(c.Close)
+	})
+	return c
+}
+
+// ShutdownDelay sets the duration to wait for callers to perceive the deregistration,
+// default 3s.
+func ShutdownDelay(delay time.Duration) Option {
+	return func(a *appOptions) {
+		a.shutdownDelay = delay
+	}
+}
+
+// ShutdownTimeout sets the timeout of the whole graceful shutdown, default 30s.
+func ShutdownTimeout(timeout time.Duration) Option {
+	return func(a *appOptions) {
+		a.shutdownTimeout = timeout
+	}
+}
+
+// shutdownRegistrar wraps the registrar, the instance is deregistered and the propagation delay
+// is waited out when kratos app stops, before the servers are stopped.
+type shutdownRegistrar struct {
+	r       registry.Registrar
+	delay   time.Duration
+	timeout time.Duration
+
+	mu        sync.Mutex
+	instances []*registry.ServiceInstance
+}
+
+func newShutdownRegistrar(r registry.Registrar, delay time.Duration, timeout time.Duration) *shutdownRegistrar {
+	s := &shutdownRegistrar{r: r, delay: delay, timeout: timeout}
+	registrarMu.Lock()
+	defaultRegistrar = s
+	registrarMu.Unlock()
+	return s
+}
+
+func (s *shutdownRegistrar) Register(ctx context.Context, ins *registry.ServiceInstance) error {
+	if s.r == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.instances = append(s.instances, ins)
+	s.mu.Unlock()
+	return s.r.Register(ctx, ins)
+}
+
+// Deregister deregisters the instances and waits for callers to perceive it,
+// the servers are stopped by kratos app after it returns.
+func (s *shutdownRegistrar) Deregister(ctx context.Context, ins *registry.ServiceInstance) error {
+	s.deregister(ctx)
+	return nil
+}
+
+// deregister deregisters all registered instances and waits the propagation delay.
+func (s *shutdownRegistrar) deregister(ctx context.Context) {
+	if s.r == nil {
+		return
+	}
+	s.mu.Lock()
+	instances := s.instances
+	s.instances = nil
+	s.mu.Unlock()
+	// 已经注销过或者没有注册实例时无需等待调用方感知
+	if len(instances) == 0 {
+		return
+	}
+	for _, ins := range instances {
+		if err := s.r.Deregister(ctx, ins); err != nil {
+			log.DefaultLog.Errorw("msg", "deregister instance failed!", "id", ins.ID, "err", err)
+		}
+	}
+	log.DefaultLog.Infow("msg", "instance deregistered,wait for propagation!", "delay", s.delay)
+	timer := time.NewTimer(s.delay)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C:
+	}
+}
+
+// Run runs the kratos app created with AppOptions, and runs Shutdown after the servers stopped.
+func Run(app *kratos.App) error {
+	err := app.Run()
+	timeout := defaultShutdownTimeout
+	registrarMu.Lock()
+	if defaultRegistrar != nil {
+		timeout = defaultRegistrar.timeout
+	}
+	registrarMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if e := Shutdown(ctx); e != nil {
+		log.DefaultLog.Errorw("msg", "graceful shutdown failed!", "err", e)
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the tsf components, it is called by Run after the servers stopped, in order:
+// 1. deregisters the instance from consul and waits the propagation delay,
+// it is already done when kratos app stops
+// 2. drains the in-flight requests counted by the server middleware
+// 3. flushes the monitor and trace exporters
+// 4. closes all watchers
+func Shutdown(ctx context.Context) error {
+	shutdownOnce.Do(func() {
+		shutdownErr = shutdown(ctx)
+	})
+	return shutdownErr
+}
+
+func shutdown(ctx context.Context) (err error) {
+	registrarMu.Lock()
+	r := defaultRegistrar
+	registrarMu.Unlock()
+	if r != nil {
+		r.deregister(ctx)
+	}
+
+	drain(ctx)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	monitor.Close()
+	if err = tracing.Shutdown(flushCtx); err != nil {
+		log.DefaultLog.Errorw("msg", "flush tracing spans failed!", "err", err)
+	}
+
+	closerMu.Lock()
+	fs := closers
+	closers = nil
+	closerMu.Unlock()
+	for _, f := range fs {
+		f()
+	}
+	// 没有使用过consul时不需要创建再关闭
+	consul.CloseDefault()
+	log.DefaultLog.Info("graceful shutdown finished!")
+	return
+}
+
+// drain waits for the in-flight requests to finish until ctx is done.
+func drain(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for Inflight() > 0 {
+		select {
+		case <-ctx.Done():
+			log.DefaultLog.Errorw("msg", "drain in-flight requests timeout!", "inflight", Inflight())
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package tsf
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+// events 按顺序记录优雅退出的各个步骤
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(event string) {
+	e.mu.Lock()
+	e.list = append(e.list, event)
+	e.mu.Unlock()
+}
+
+func (e *events) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.list...)
+}
+
+type testRegistrar struct {
+	e *events
+}
+
+func (r *testRegistrar) Register(ctx context.Context, ins *registry.ServiceInstance) error {
+	r.e.add("register")
+	return nil
+}
+
+func (r *testRegistrar) Deregister(ctx context.Context, ins *registry.ServiceInstance) error {
+	r.e.add("deregister")
+	return nil
+}
+
+type testServer struct {
+	e *events
+}
+
+func (s *testServer) Start(ctx context.Context) error { return nil }
+
+func (s *testServer) Stop(ctx context.Context) error {
+	s.e.add("stop")
+	return nil
+}
+
+func TestShutdownOrder(t *testing.T) {
+	e := &events{}
+	delay := 100 * time.Millisecond
+	app := kratos.New(
+		kratos.Name("provider-shutdown"),
+		kratos.Server(&testServer{e: e}),
+		kratos.Registrar(newShutdownRegistrar(&testRegistrar{e: e}, delay, time.Second)),
+	)
+	onShutdown(func() { e.add("close") })
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Run(app)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(e.get()) > 0
+	}, time.Second, time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, app.Stop())
+	// 注销实例并等待调用方感知之后才停止server
+	assert.True(t, time.Since(start) >= delay)
+	assert.Equal(t, []string{"register", "deregister"}, e.get())
+	select {
+	case err := <-errc:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("app does not stop")
+	}
+	// server停止之后才刷新数据并关闭watcher
+	assert.Equal(t, []string{"register", "deregister", "stop", "close"}, e.get())
+}
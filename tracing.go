@@ -115,6 +115,9 @@ func tracingClient() middleware.Middleware {
 				span.SetAttributes(attribute.String("peer.service", remoteService))
 				span.SetAttributes(attribute.String("http.method", method))
 				span.SetAttributes(attribute.String("http.path", path))
+				if attempt := retryAttempt(ctx); attempt > 0 {
+					span.SetAttributes(attribute.Int("retry.attempt", attempt))
+				}
 				defer func() {
 					if tr.Kind() == transport.KindHTTP {
 						if ht, ok := tr.(*http.Transport); ok {
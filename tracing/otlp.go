@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+var _ tracesdk.SpanExporter = &OTLPExporter{}
+
+// OTLPExporter exports spans to the OpenTelemetry collector by OTLP/HTTP.
+// The tsf local endpoint attributes are kept as span attributes and also set as the resource of span,
+// the local and peer endpoint are mapped to the semantic conventions attributes as well, e.g. net.peer.ip
+type OTLPExporter struct {
+	exporter tracesdk.SpanExporter
+}
+
+// NewOTLPExporter new an OTLP/HTTP exporter, the default endpoint is localhost:4318
+func NewOTLPExporter(ctx context.Context, opts ...otlptracehttp.Option) (*OTLPExporter, error) {
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &OTLPExporter{exporter: exporter}, nil
+}
+
+func (e *OTLPExporter) ExportSpans(ctx context.Context, ss []tracesdk.ReadOnlySpan) error {
+	spans := make([]tracesdk.ReadOnlySpan, 0, len(ss))
+	for _, s := range ss {
+		spans = append(spans, otlpSpan(s))
+	}
+	return e.exporter.ExportSpans(ctx, spans)
+}
+
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
+// endpointSpan overrides the attributes and resource of span.
+type endpointSpan struct {
+	// ReadOnlySpan有私有方法，只能通过嵌入实现
+	tracesdk.ReadOnlySpan
+	attrs []attribute.KeyValue
+	res   *resource.Resource
+}
+
+func (s *endpointSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+func (s *endpointSpan) Resource() *resource.Resource {
+	return s.res
+}
+
+func otlpSpan(s tracesdk.ReadOnlySpan) tracesdk.ReadOnlySpan {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range s.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	spanAttrs := s.Attributes()
+	var resAttrs []attribute.KeyValue
+	if v, ok := attrs["local.service"]; ok {
+		resAttrs = append(resAttrs, semconv.ServiceNameKey.String(v.AsString()), attribute.String("local.service", v.AsString()))
+	}
+	if v, ok := attrs["local.ip"]; ok {
+		resAttrs = append(resAttrs, attribute.String("local.ip", v.AsString()))
+		spanAttrs = append(spanAttrs, semconv.NetHostIPKey.String(v.AsString()))
+	}
+	if v, ok := attrs["local.port"]; ok {
+		resAttrs = append(resAttrs, attribute.Int64("local.port", v.AsInt64()))
+		spanAttrs = append(spanAttrs, semconv.NetHostPortKey.Int64(v.AsInt64()))
+	}
+	if v, ok := attrs["peer.ip"]; ok {
+		spanAttrs = append(spanAttrs, semconv.NetPeerIPKey.String(v.AsString()))
+	}
+	if v, ok := attrs["peer.port"]; ok {
+		spanAttrs = append(spanAttrs, semconv.NetPeerPortKey.Int64(v.AsInt64()))
+	}
+	res := s.Resource()
+	if len(resAttrs) > 0 {
+		// 使用span上的本地服务信息覆盖provider的resource
+		merged, err := resource.Merge(res, resource.NewSchemaless(resAttrs...))
+		if err == nil {
+			res = merged
+		}
+	}
+	return &endpointSpan{ReadOnlySpan: s, attrs: spanAttrs, res: res}
+}
+
+// Tee returns an exporter which exports spans to all the exporters.
+func Tee(exporters ...tracesdk.SpanExporter) tracesdk.SpanExporter {
+	return teeExporter(exporters)
+}
+
+type teeExporter []tracesdk.SpanExporter
+
+func (t teeExporter) ExportSpans(ctx context.Context, ss []tracesdk.ReadOnlySpan) (err error) {
+	for _, e := range t {
+		if e1 := e.ExportSpans(ctx, ss); e1 != nil && err == nil {
+			err = e1
+		}
+	}
+	return
+}
+
+func (t teeExporter) Shutdown(ctx context.Context) (err error) {
+	for _, e := range t {
+		if e1 := e.Shutdown(ctx); e1 != nil && err == nil {
+			err = e1
+		}
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/proto"
+)
+
+func attrs(kvs []*commonv1.KeyValue) map[string]string {
+	res := make(map[string]string)
+	for _, kv := range kvs {
+		switch v := kv.Value.Value.(type) {
+		case *commonv1.AnyValue_StringValue:
+			res[kv.Key] = v.StringValue
+		case *commonv1.AnyValue_IntValue:
+			res[kv.Key] = attribute.Int64Value(v.IntValue).Emit()
+		}
+	}
+	return res
+}
+
+func TestOTLPExporter(t *testing.T) {
+	reqs := make(chan *collectortrace.ExportTraceServiceRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		var req collectortrace.ExportTraceServiceRequest
+		assert.Nil(t, proto.Unmarshal(body, &req))
+		reqs <- &req
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	exporter, err := NewOTLPExporter(context.Background(),
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+	)
+	assert.Nil(t, err)
+	core, logs := observer.New(zap.InfoLevel)
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(Tee(&Exporter{zap.New(core)}, exporter)))
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("CLIENT").Start(context.Background(), "/hello", trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(
+		attribute.String("local.service", "consumer"),
+		attribute.String("local.ip", "10.0.0.1"),
+		attribute.Int64("local.port", 8080),
+		attribute.String("peer.service", "provider"),
+		attribute.String("peer.ip", "10.0.0.2"),
+		attribute.Int64("peer.port", 9090),
+	)
+	span.End()
+
+	req := <-reqs
+	assert.Equal(t, 1, len(req.ResourceSpans))
+	rs := req.ResourceSpans[0]
+	res := attrs(rs.Resource.Attributes)
+	assert.Equal(t, "consumer", res["service.name"])
+	assert.Equal(t, "10.0.0.1", res["local.ip"])
+	assert.Equal(t, "8080", res["local.port"])
+	spans := rs.InstrumentationLibrarySpans[0].Spans
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "/hello", spans[0].Name)
+	tags := attrs(spans[0].Attributes)
+	assert.Equal(t, "provider", tags["peer.service"])
+	assert.Equal(t, "10.0.0.2", tags["peer.ip"])
+	assert.Equal(t, "10.0.0.2", tags["net.peer.ip"])
+	assert.Equal(t, "9090", tags["net.peer.port"])
+	assert.Equal(t, "10.0.0.1", tags["net.host.ip"])
+	assert.Equal(t, "consumer", tags["local.service"])
+
+	// 同时写入TSF调用链日志
+	assert.Equal(t, 1, logs.Len())
+	content := logs.All()[0].Message
+	assert.Contains(t, content, `"localEndpoint":{"serviceName":"consumer","ipv4":"10.0.0.1","port":8080}`)
+	assert.Contains(t, content, `"remoteEndpoint":{"serviceName":"provider","ipv4":"10.0.0.2","port":9090}`)
+}
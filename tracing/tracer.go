@@ -3,6 +3,7 @@ package tracing
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/go-kratos/kratos/v2/errors"
 	"go.opentelemetry.io/contrib/propagators/b3"
@@ -47,6 +48,11 @@ type Span struct {
 	Tags           map[string]string `json:"tags,omitempty"`
 }
 
+var (
+	mu       sync.Mutex
+	provider *tracesdk.TracerProvider
+)
+
 // Option is tracing option.
 type Option func(*options)
 
@@ -63,6 +69,18 @@ func WithTracerExporter(exporter tracesdk.SpanExporter) Option {
 	}
 }
 
+// WithOTLPExporter exports spans to the OpenTelemetry collector,
+// if tee is true the spans are also written to the TSF trace log.
+func WithOTLPExporter(exporter *OTLPExporter, tee bool) Option {
+	return func(opts *options) {
+		if tee {
+			opts.exporter = Tee(&Exporter{defaultLogger}, exporter)
+			return
+		}
+		opts.exporter = exporter
+	}
+}
+
 // WithResource with tracer resource.
 func WithResource(r *resource.Resource) Option {
 	return func(opts *options) {
@@ -91,6 +109,20 @@ func SetProvider(opts ...Option) {
 	}
 	tp := tracerProvider(options.sampleRatio, options.exporter, options.r)
 	otel.SetTracerProvider(tp)
+	mu.Lock()
+	provider = tp
+	mu.Unlock()
+}
+
+// Shutdown flushes the spans not exported yet and stops the provider set by SetProvider.
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	tp := provider
+	mu.Unlock()
+	if tp == nil {
+		return nil
+	}
+	return tp.Shutdown(ctx)
 }
 
 func init() {
@@ -111,6 +143,8 @@ func NewTracer(kind trace.SpanKind) (*Tracer, error) {
 		return &Tracer{tracer: otel.Tracer("CLIENT"), kind: kind}, nil
 	case trace.SpanKindServer:
 		return &Tracer{tracer: otel.Tracer("SERVER"), kind: kind}, nil
+	case trace.SpanKindInternal:
+		return &Tracer{tracer: otel.Tracer("INTERNAL"), kind: kind}, nil
 	default:
 		return nil, fmt.Errorf("unsupported span kind: %v", kind)
 	}
@@ -0,0 +1,83 @@
+package tsf
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+	"github.com/tencentyun/tsf-go/tsftest"
+)
+
+const testNamespace = "namespace-test"
+
+// fakeConsul 是默认consul客户端指向的fake consul
+var fakeConsul *tsftest.Consul
+
+func TestMain(m *testing.M) {
+	fakeConsul = tsftest.NewConsul()
+	host, port, _ := net.SplitHostPort(fakeConsul.Addr())
+	flag.Set("tsf_consul_ip", host)
+	flag.Set("tsf_consul_port", port)
+	flag.Set("tsf_namespace_id", testNamespace)
+	code := m.Run()
+	fakeConsul.Close()
+	// 清理非tsf环境下tracing默认写入的./trace目录
+	if env.TracePath() == "./trace/trace_log.log" {
+		os.RemoveAll("./trace")
+	}
+	os.Exit(code)
+}
+
+type header map[string]string
+
+func (h header) Get(key string) string { return h[key] }
+
+func (h header) Set(key string, value string) { h[key] = value }
+
+func (h header) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+type testTransport struct {
+	endpoint  string
+	operation string
+	reqHeader header
+	repHeader header
+}
+
+func (t *testTransport) Kind() transport.Kind            { return transport.KindGRPC }
+func (t *testTransport) Endpoint() string                { return t.endpoint }
+func (t *testTransport) Operation() string               { return t.operation }
+func (t *testTransport) RequestHeader() transport.Header { return t.reqHeader }
+func (t *testTransport) ReplyHeader() transport.Header   { return t.repHeader }
+
+// serverContext returns the context of a grpc request to the operation of service name.
+func serverContext(name string, operation string) context.Context {
+	ctx := kratos.NewContext(context.Background(), kratos.New(kratos.Name(name)))
+	return transport.NewServerContext(ctx, &testTransport{
+		endpoint:  "grpc://127.0.0.1:9000",
+		operation: operation,
+		reqHeader: header{},
+		repHeader: header{},
+	})
+}
+
+// clientContext returns the context of a grpc call from service name to the operation of target.
+func clientContext(name string, target string, operation string) context.Context {
+	ctx := kratos.NewContext(context.Background(), kratos.New(kratos.Name(name)))
+	return transport.NewClientContext(ctx, &testTransport{
+		endpoint:  "discovery:///" + target,
+		operation: operation,
+		reqHeader: header{},
+		repHeader: header{},
+	})
+}
@@ -0,0 +1,350 @@
+// Package tsftest provides utilities for tsf-go integration tests.
+package tsftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tencentyun/tsf-go/naming"
+)
+
+const (
+	statusPassing  = "passing"
+	statusCritical = "critical"
+
+	// 阻塞查询的最长等待时间
+	maxWait = 10 * time.Minute
+)
+
+type service struct {
+	ID      string
+	Service string
+	Tags    []string
+	Address string
+	Meta    map[string]string
+	Port    int
+	status  string
+}
+
+type kvPair struct {
+	Key         string
+	Value       []byte
+	CreateIndex int64
+	ModifyIndex int64
+}
+
+// Consul is an in-process fake consul server, implementing the subset of apis used by tsf-go:
+// agent service register/deregister, ttl check pass, blocking health service and kv queries and catalog services.
+type Consul struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	index    int64
+	changed  chan struct{}
+	services map[string]*service
+	kv       map[string]*kvPair
+	token    string
+	// 每个key被查询的次数
+	queries map[string]int
+}
+
+// NewConsul starts a fake consul server.
+func NewConsul() *Consul {
+	c := &Consul{
+		index:    1,
+		changed:  make(chan struct{}),
+		services: make(map[string]*service),
+		kv:       make(map[string]*kvPair),
+		queries:  make(map[string]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/service/register", c.register)
+	mux.HandleFunc("/v1/agent/service/deregister/", c.deregister)
+	mux.HandleFunc("/v1/agent/check/pass/", c.pass)
+	mux.HandleFunc("/v1/health/service/", c.health)
+	mux.HandleFunc("/v1/catalog/services", c.catalog)
+	mux.HandleFunc("/v1/kv/", c.kvGet)
+	c.srv = httptest.NewServer(c.auth(mux))
+	return c
+}
+
+// Addr returns the address of consul, e.g. 127.0.0.1:8500
+func (c *Consul) Addr() string {
+	return strings.TrimPrefix(c.srv.URL, "http://")
+}
+
+// Close shuts down the server.
+func (c *Consul) Close() {
+	c.srv.CloseClientConnections()
+	c.srv.Close()
+}
+
+// SetToken requires the X-Consul-Token header of every request.
+func (c *Consul) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+// Register registers a healthy instance.
+func (c *Consul) Register(ins *naming.Instance) {
+	c.update(func() {
+		c.services[ins.ID] = &service{
+			ID:      ins.ID,
+			Service: ins.Service.Name,
+			Tags:    ins.Tags,
+			Address: ins.Host,
+			Meta:    ins.Metadata,
+			Port:    ins.Port,
+			status:  statusPassing,
+		}
+	})
+}
+
+// Deregister deregisters the instance.
+func (c *Consul) Deregister(id string) {
+	c.update(func() {
+		delete(c.services, id)
+	})
+}
+
+// SetHealthy sets the health check status of instance.
+func (c *Consul) SetHealthy(id string, healthy bool) {
+	c.update(func() {
+		if svc, ok := c.services[id]; ok {
+			svc.status = statusCritical
+			if healthy {
+				svc.status = statusPassing
+			}
+		}
+	})
+}
+
+// Put sets the value of key.
+func (c *Consul) Put(key string, value []byte) {
+	c.update(func() {
+		pair, ok := c.kv[key]
+		if !ok {
+			pair = &kvPair{Key: key, CreateIndex: c.index + 1}
+			c.kv[key] = pair
+		}
+		pair.Value = value
+		pair.ModifyIndex = c.index + 1
+	})
+}
+
+// Delete deletes the key.
+func (c *Consul) Delete(key string) {
+	c.update(func() {
+		delete(c.kv, key)
+	})
+}
+
+// PutRoute pushes the route rule of service.
+func (c *Consul) PutRoute(namespaceID, serviceName, rule string) {
+	c.Put(fmt.Sprintf("route/%s/%s/data", namespaceID, serviceName), []byte(rule))
+}
+
+// PutLaneRule pushes the lane rule.
+func (c *Consul) PutLaneRule(ruleID, rule string) {
+	c.Put(fmt.Sprintf("lane/rule/%s/data", ruleID), []byte(rule))
+}
+
+// PutLane pushes the lane info.
+func (c *Consul) PutLane(laneID, lane string) {
+	c.Put(fmt.Sprintf("lane/info/%s/data", laneID), []byte(lane))
+}
+
+// PutAuth pushes the auth rule of service.
+func (c *Consul) PutAuth(namespaceID, serviceName, rule string) {
+	c.Put(fmt.Sprintf("authority/%s/%s/data", namespaceID, serviceName), []byte(rule))
+}
+
+// Queries returns the number of kv queries of key, including the blocking queries.
+func (c *Consul) Queries(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.queries[key]
+}
+
+// update modifies the data and wakes up the blocking queries.
+func (c *Consul) update(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f()
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *Consul) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		token := c.token
+		c.mu.Unlock()
+		// 和consul一样支持header和query参数两种方式传递token
+		if token != "" && r.Header.Get("X-Consul-Token") != token && r.URL.Query().Get("token") != token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// block waits until the index is changed if the request is a blocking query, then runs f with the lock held.
+func (c *Consul) block(w http.ResponseWriter, r *http.Request, f func() (interface{}, bool)) {
+	index, _ := strconv.ParseInt(r.URL.Query().Get("index"), 10, 64)
+	wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+	if err != nil || wait > maxWait {
+		wait = maxWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var expired bool
+	c.mu.Lock()
+	for index > 0 && index >= c.index && !expired {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timer.C:
+			expired = true
+		case <-r.Context().Done():
+			return
+		}
+		c.mu.Lock()
+	}
+	res, ok := f()
+	current := c.index
+	c.mu.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatInt(current, 10))
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+type serviceDefinition struct {
+	ID      string
+	Name    string
+	Tags    []string
+	Address string
+	Meta    map[string]string
+	Port    int
+}
+
+func (c *Consul) register(w http.ResponseWriter, r *http.Request) {
+	var sd serviceDefinition
+	if err := json.NewDecoder(r.Body).Decode(&sd); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sd.ID == "" {
+		sd.ID = sd.Name
+	}
+	c.update(func() {
+		status := statusCritical
+		if old, ok := c.services[sd.ID]; ok {
+			status = old.status
+		}
+		// ttl检查在收到心跳前是critical
+		c.services[sd.ID] = &service{
+			ID:      sd.ID,
+			Service: sd.Name,
+			Tags:    sd.Tags,
+			Address: sd.Address,
+			Meta:    sd.Meta,
+			Port:    sd.Port,
+			status:  status,
+		}
+	})
+}
+
+func (c *Consul) deregister(w http.ResponseWriter, r *http.Request) {
+	c.Deregister(strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+}
+
+func (c *Consul) pass(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/agent/check/pass/"), "service:")
+	c.mu.Lock()
+	svc, ok := c.services[id]
+	passing := ok && svc.status == statusPassing
+	c.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("CheckID %q does not have associated TTL", "service:"+id), http.StatusNotFound)
+		return
+	}
+	if !passing {
+		c.SetHealthy(id, true)
+	}
+}
+
+type checkServiceNode struct {
+	Node    map[string]string
+	Service *service
+	Checks  []map[string]string
+}
+
+func (c *Consul) health(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	_, passing := r.URL.Query()["passing"]
+	c.block(w, r, func() (interface{}, bool) {
+		nodes := []checkServiceNode{}
+		for _, svc := range c.services {
+			if svc.Service != name || (passing && svc.status != statusPassing) {
+				continue
+			}
+			s := *svc
+			nodes = append(nodes, checkServiceNode{
+				Node:    map[string]string{"Node": "tsftest", "Address": svc.Address},
+				Service: &s,
+				Checks:  []map[string]string{{"CheckID": "service:" + svc.ID, "Status": svc.status}},
+			})
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].Service.ID < nodes[j].Service.ID
+		})
+		return nodes, true
+	})
+}
+
+func (c *Consul) catalog(w http.ResponseWriter, r *http.Request) {
+	c.block(w, r, func() (interface{}, bool) {
+		services := make(map[string][]string)
+		for _, svc := range c.services {
+			services[svc.Service] = append(services[svc.Service], svc.Tags...)
+		}
+		return services, true
+	})
+}
+
+func (c *Consul) kvGet(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	_, recurse := r.URL.Query()["recurse"]
+	c.mu.Lock()
+	c.queries[key]++
+	c.mu.Unlock()
+	c.block(w, r, func() (interface{}, bool) {
+		var pairs []*kvPair
+		for k, pair := range c.kv {
+			if k == key || (recurse && strings.HasPrefix(k, key)) {
+				p := *pair
+				pairs = append(pairs, &p)
+			}
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i].Key < pairs[j].Key
+		})
+		return pairs, len(pairs) > 0
+	})
+}
@@ -0,0 +1,113 @@
+package tsftest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/naming/consul"
+	"github.com/tencentyun/tsf-go/pkg/auth/authenticator"
+	configConsul "github.com/tencentyun/tsf-go/pkg/config/consul"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	pkgNaming "github.com/tencentyun/tsf-go/pkg/naming"
+)
+
+const authRule = `- type: B
+  rules:
+  - ruleId: rule-1
+    tags:
+    - tagType: U
+      tagField: user
+      tagOperator: EQUAL
+      tagValue: bad
+`
+
+func next(t *testing.T, w registry.Watcher) []*registry.ServiceInstance {
+	res := make(chan []*registry.ServiceInstance, 1)
+	go func() {
+		nodes, _ := w.Next()
+		res <- nodes
+	}()
+	select {
+	case nodes := <-res:
+		return nodes
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch timeout")
+	}
+	return nil
+}
+
+func TestDiscovery(t *testing.T) {
+	c := NewConsul()
+	defer c.Close()
+	c.SetToken("token")
+
+	cli := consul.New(&consul.Config{Address: []string{c.Addr()}, Token: "token"})
+	defer cli.Close()
+	ins := naming.Instance{Service: naming.NewService("", "provider"), ID: "provider-1", Host: "127.0.0.1", Port: 8080}
+	assert.Nil(t, cli.Register(context.Background(), ins.ToKratosInstance()))
+
+	w, err := cli.Watch(context.Background(), "provider")
+	assert.Nil(t, err)
+	defer w.Stop()
+	nodes := next(t, w)
+	assert.Equal(t, 1, len(nodes))
+	assert.Equal(t, "provider-1", nodes[0].ID)
+
+	// 阻塞查询在实例变化时返回
+	c.Register(&naming.Instance{Service: naming.NewService("", "provider"), ID: "provider-2", Host: "127.0.0.1", Port: 8081})
+	assert.Equal(t, 2, len(next(t, w)))
+	c.SetHealthy("provider-2", false)
+	assert.Equal(t, 1, len(next(t, w)))
+
+	// 没有token无法访问
+	noToken := consul.New(&consul.Config{Address: []string{c.Addr()}})
+	defer noToken.Close()
+	assert.NotNil(t, noToken.Register(context.Background(), ins.ToKratosInstance()))
+}
+
+func TestConfig(t *testing.T) {
+	c := NewConsul()
+	defer c.Close()
+
+	source := configConsul.New(&configConsul.Config{Address: c.Addr()})
+	w := source.Subscribe("lane/info/")
+	defer w.Close()
+	specs, err := w.Watch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(specs))
+
+	c.PutLane("lane-1", "id: lane-1")
+	c.PutLane("lane-2", "id: lane-2")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for len(specs) < 2 {
+		specs, err = w.Watch(ctx)
+		if !assert.Nil(t, err) {
+			return
+		}
+	}
+	assert.Equal(t, "lane/info/lane-1/data", specs[0].Key)
+	assert.Equal(t, "id: lane-2", string(specs[1].Data.Raw()))
+}
+
+func TestAuth(t *testing.T) {
+	c := NewConsul()
+	defer c.Close()
+
+	source := configConsul.New(&configConsul.Config{Address: c.Addr()})
+	builder := &authenticator.Builder{}
+	a := builder.Build(source, pkgNaming.Service{Namespace: "ns", Name: "provider"})
+	ctx := meta.WithUser(context.Background(), meta.UserPair{Key: "user", Value: "bad"})
+	assert.Nil(t, a.Verify(ctx, "/hello"))
+
+	c.PutAuth("ns", "provider", authRule)
+	assert.Eventually(t, func() bool {
+		return errors.IsForbidden(a.Verify(ctx, "/hello"))
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, a.Verify(context.Background(), "/hello"))
+}
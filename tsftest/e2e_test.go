@@ -0,0 +1,243 @@
+package tsftest
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net"
+	nhttp "net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/stretchr/testify/assert"
+	tsf "github.com/tencentyun/tsf-go"
+	"github.com/tencentyun/tsf-go/naming"
+	"github.com/tencentyun/tsf-go/pkg/meta"
+	"github.com/tencentyun/tsf-go/pkg/sys/env"
+	"github.com/tencentyun/tsf-go/testdata"
+)
+
+const (
+	e2eNamespace = "namespace-e2e"
+	e2eGroup     = "group-consumer"
+)
+
+// e2e 是端到端测试共用的consul，tsf-go的默认consul客户端都指向它
+var e2e *Consul
+
+func TestMain(m *testing.M) {
+	e2e = NewConsul()
+	host, port, _ := net.SplitHostPort(e2e.Addr())
+	flag.Set("tsf_consul_ip", host)
+	flag.Set("tsf_consul_port", port)
+	flag.Set("tsf_namespace_id", e2eNamespace)
+	flag.Set("tsf_group_id", e2eGroup)
+	code := m.Run()
+	e2e.Close()
+	// 清理非tsf环境下tracing默认写入的./trace目录
+	if env.TracePath() == "./trace/trace_log.log" {
+		os.RemoveAll("./trace")
+	}
+	os.Exit(code)
+}
+
+// startApp runs a kratos app with the server and registers it to the e2e consul.
+func startApp(t *testing.T, name string, srv transport.Server, metadata map[string]string) (stop func()) {
+	app := kratos.New(kratos.Name(name), kratos.Server(srv))
+	go app.Run()
+
+	endpoint, err := srv.(transport.Endpointer).Endpoint()
+	assert.Nil(t, err)
+	host, port, _ := net.SplitHostPort(endpoint.Host)
+	portNum, _ := strconv.Atoi(port)
+	md := map[string]string{"protocol": endpoint.Scheme, naming.NamespaceID: e2eNamespace}
+	for k, v := range metadata {
+		md[k] = v
+	}
+	ins := &naming.Instance{
+		Service:  naming.NewService(e2eNamespace, name),
+		ID:       name + "-" + port,
+		Host:     host,
+		Port:     portNum,
+		Metadata: md,
+	}
+	e2e.Register(ins)
+	return func() {
+		e2e.Deregister(ins.ID)
+		app.Stop()
+	}
+}
+
+func groupServer(group string) *http.Server {
+	srv := http.NewServer(http.Address("127.0.0.1:0"))
+	srv.HandleFunc("/group", func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"group": group})
+	})
+	return srv
+}
+
+const routeRule = `- routeId: route-1
+  microserviceName: provider-route
+  namespaceId: namespace-e2e
+  fallbackStatus: false
+  ruleList:
+  - routeRuleId: route-r-1
+    tagList:
+    - tagType: U
+      tagField: user
+      tagOperator: EQUAL
+      tagValue: vip
+    destList:
+    - destId: route-d-1
+      destWeight: 100
+      destItemList:
+      - destItemField: TSF_GROUP_ID
+        destItemValue: group-2
+`
+
+func newGroupClient(t *testing.T, name string) (call func(ctx context.Context) string, close func()) {
+	opts := []http.ClientOption{http.WithEndpoint("discovery:///" + name), http.WithBlock()}
+	cli, err := http.NewClient(context.Background(), append(opts, tsf.ClientHTTPOptions()...)...)
+	if err != nil {
+		t.Fatalf("new http client failed!err:=%v", err)
+	}
+	return func(ctx context.Context) string {
+		var reply struct {
+			Group string `json:"group"`
+		}
+		if err := cli.Invoke(ctx, "GET", "/group", nil, &reply); err != nil {
+			t.Logf("invoke %s failed!err:=%v", name, err)
+		}
+		return reply.Group
+	}, func() { cli.Close() }
+}
+
+// always checks that all of n calls reach the groups.
+func always(call func() string, n int, groups ...string) bool {
+	for i := 0; i < n; i++ {
+		group := call()
+		var ok bool
+		for _, g := range groups {
+			ok = ok || g == group
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRouteE2E(t *testing.T) {
+	defer startApp(t, "provider-route", groupServer("group-1"), map[string]string{naming.GroupID: "group-1"})()
+	defer startApp(t, "provider-route", groupServer("group-2"), map[string]string{naming.GroupID: "group-2"})()
+	call, close := newGroupClient(t, "provider-route")
+	defer close()
+
+	vip := meta.WithUser(context.Background(), meta.UserPair{Key: "user", Value: "vip"})
+	// 没有路由规则时两个分组都会被调用
+	groups := make(map[string]bool)
+	assert.Eventually(t, func() bool {
+		groups[call(vip)] = true
+		return groups["group-1"] && groups["group-2"]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	e2e.PutRoute(e2eNamespace, "provider-route", routeRule)
+	assert.Eventually(t, func() bool {
+		return always(func() string { return call(vip) }, 20, "group-2")
+	}, 5*time.Second, 10*time.Millisecond)
+	// 未命中规则的请求不受影响
+	groups = make(map[string]bool)
+	assert.Eventually(t, func() bool {
+		groups[call(context.Background())] = true
+		return groups["group-1"] && groups["group-2"]
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// 泳道入口是调用方所在的部署组group-consumer，group-lane是泳道中的染色部署组
+const laneInfo = `laneId: lane-1
+laneName: lane-1
+laneGroupList:
+- groupId: group-consumer
+  entrance: true
+  applicationId: application-consumer
+  namespaceId: namespace-e2e
+- groupId: group-lane
+  entrance: false
+  applicationId: application-lane
+  namespaceId: namespace-e2e
+`
+
+const laneRule = `ruleId: lane-rule-1
+ruleName: lane-rule-1
+priority: 1
+enable: true
+laneId: lane-1
+ruleTagRelationship: RELEATION_AND
+ruleTagList:
+- tagName: user
+  tagOperator: EQUAL
+  tagValue: blue
+`
+
+func TestLaneE2E(t *testing.T) {
+	defer startApp(t, "provider-lane", groupServer("group-normal"), map[string]string{naming.GroupID: "group-normal", naming.ApplicationID: "application-lane"})()
+	defer startApp(t, "provider-lane", groupServer("group-lane"), map[string]string{naming.GroupID: "group-lane", naming.ApplicationID: "application-lane"})()
+	call, close := newGroupClient(t, "provider-lane")
+	defer close()
+
+	blue := meta.WithUser(context.Background(), meta.UserPair{Key: "user", Value: "blue"})
+	e2e.PutLaneRule("lane-rule-1", laneRule)
+	e2e.PutLane("lane-1", laneInfo)
+	// 命中泳道规则的请求只调用染色部署组
+	assert.Eventually(t, func() bool {
+		return always(func() string { return call(blue) }, 20, "group-lane")
+	}, 5*time.Second, 10*time.Millisecond)
+	// 其余请求不会调用染色部署组
+	assert.Eventually(t, func() bool {
+		return always(func() string { return call(context.Background()) }, 20, "group-normal")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAuthE2E(t *testing.T) {
+	srv := grpc.NewServer(grpc.Address("127.0.0.1:0"), grpc.Middleware(tsf.ServerMiddleware()))
+	testdata.RegisterGreeterServer(srv.Server, &greeter{})
+	defer startApp(t, "provider-auth", srv, nil)()
+
+	opts := []grpc.ClientOption{grpc.WithEndpoint("discovery:///provider-auth"), grpc.WithTimeout(5 * time.Second)}
+	opts = append(opts, tsf.ClientGrpcOptions()...)
+	conn, err := grpc.DialInsecure(context.Background(), opts...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	cli := testdata.NewGreeterClient(conn)
+
+	bad := meta.WithUser(context.Background(), meta.UserPair{Key: "user", Value: "bad"})
+	reply, err := cli.SayHello(bad, &testdata.HelloRequest{Name: "tsf"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "hello tsf", reply.Message)
+	}
+
+	e2e.PutAuth(e2eNamespace, "provider-auth", authRule)
+	assert.Eventually(t, func() bool {
+		_, err := cli.SayHello(bad, &testdata.HelloRequest{Name: "tsf"})
+		return errors.IsForbidden(err)
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = cli.SayHello(context.Background(), &testdata.HelloRequest{Name: "tsf"})
+	assert.Nil(t, err)
+}
+
+type greeter struct {
+	testdata.UnimplementedGreeterServer
+}
+
+func (g *greeter) SayHello(ctx context.Context, in *testdata.HelloRequest) (*testdata.HelloReply, error) {
+	return &testdata.HelloReply{Message: "hello " + in.Name}, nil
+}